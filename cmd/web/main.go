@@ -12,14 +12,19 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/go-playground/form/v4"
 	"github.com/gorilla/securecookie"
 	"github.com/robyparr/event-horizon/internal"
+	"github.com/robyparr/event-horizon/internal/bots"
 	"github.com/robyparr/event-horizon/internal/handlers"
 	"github.com/robyparr/event-horizon/internal/models"
+	"github.com/robyparr/event-horizon/internal/ratelimit"
+	"github.com/robyparr/event-horizon/internal/realtime"
+	"github.com/robyparr/event-horizon/internal/tracking"
 	"github.com/robyparr/event-horizon/internal/views"
 
 	_ "github.com/lib/pq"
@@ -31,6 +36,21 @@ func main() {
 		Host:        cmp.Or(os.Getenv("HOST"), ":4000"),
 		DatabaseURL: os.Getenv("DATABASE_URL"),
 		SkipTLS:     os.Getenv("SKIP_TLS") == "true",
+
+		ReferrerSourcesPath: os.Getenv("REFERRER_SOURCES_PATH"),
+		GeoDBPath:           os.Getenv("GEOIP_DB_PATH"),
+
+		IngestQueueSize:     envInt("INGEST_QUEUE_SIZE", 10000),
+		IngestWorkers:       envInt("INGEST_WORKERS", 4),
+		IngestBatchSize:     envInt("INGEST_BATCH_SIZE", 500),
+		IngestFlushInterval: envDuration("INGEST_FLUSH_INTERVAL", time.Second),
+
+		RateLimitPerToken: envInt("RATE_LIMIT_PER_TOKEN", 0),
+		RateLimitPerIP:    envInt("RATE_LIMIT_PER_IP", 120),
+
+		BotUserAgentsPath: os.Getenv("BOT_USER_AGENTS_PATH"),
+		BotNetworksPath:   os.Getenv("BOT_NETWORKS_PATH"),
+		BotEventRate:      envInt("BOT_EVENT_RATE", 60),
 	}
 
 	views, err := views.CompileViews()
@@ -38,6 +58,33 @@ func main() {
 		log.Fatalf("[CompileViews] %s", err.Error())
 	}
 
+	config.TrustedProxies = internal.DefaultTrustedProxies
+	if proxies, ok := os.LookupEnv("TRUSTED_PROXIES"); ok {
+		config.TrustedProxies, err = internal.ParseTrustedProxies(proxies)
+		if err != nil {
+			log.Fatalf("[ParseTrustedProxies] %s", err.Error())
+		}
+	}
+
+	referrerSources, err := loadReferrerSources(config.ReferrerSourcesPath)
+	if err != nil {
+		log.Fatalf("[loadReferrerSources] %s", err.Error())
+	}
+
+	botDetector, err := loadBotDetector(config)
+	if err != nil {
+		log.Fatalf("[loadBotDetector] %s", err.Error())
+	}
+
+	var geoDB *tracking.GeoDB
+	if config.GeoDBPath != "" {
+		geoDB, err = tracking.OpenGeoDB(config.GeoDBPath)
+		if err != nil {
+			log.Fatalf("[OpenGeoDB] %s", err.Error())
+		}
+		defer geoDB.Close()
+	}
+
 	db, err := openDB(config.DatabaseURL)
 	if err != nil {
 		log.Fatalf("[openDB] %s", err.Error())
@@ -56,6 +103,16 @@ func main() {
 		Repos:        models.NewRepos(db),
 		SecureCookie: securecookie.New([]byte(cookieSecretKey), nil),
 		StartedAt:    time.Now().UTC(),
+
+		ReferrerSources: referrerSources,
+		GeoDB:           geoDB,
+		Realtime:        realtime.NewBroker(),
+		RateLimits: internal.NewRateLimits(
+			ratelimit.Limit{Requests: config.RateLimitPerToken, Per: time.Minute},
+			ratelimit.Limit{Requests: config.RateLimitPerIP, Per: time.Minute},
+		),
+		Rejections: internal.NewRejections(),
+		Bots:       botDetector,
 	}
 
 	server := &http.Server{
@@ -70,6 +127,11 @@ func main() {
 		},
 	}
 
+	// Realtime streams never finish on their own, so end them for shutdown
+	// rather than waiting out its timeout.
+	server.RegisterOnShutdown(app.Realtime.Close)
+
+	app.StartIngestion()
 	startBackgroundProcesses(app)
 	shutdownError := gracefulShutdown(app, server)
 	app.Logger.Info("starting server", "address", server.Addr)
@@ -113,6 +175,80 @@ func openDB(dsn string) (*sql.DB, error) {
 	return db, nil
 }
 
+// envInt reads an integer setting, using fallback when it's unset or isn't a
+// number. Zero is kept, since it turns off the limits it's used for.
+func envInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+
+	return value
+}
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+
+	return value
+}
+
+func loadReferrerSources(path string) (*tracking.Sources, error) {
+	if path == "" {
+		return tracking.DefaultSources(), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return tracking.LoadSources(f)
+}
+
+// loadBotDetector checks user agents first, then data center networks if
+// they're configured, and lastly the heuristics.
+func loadBotDetector(config internal.Config) (*bots.Detector, error) {
+	userAgents := bots.DefaultUserAgentCheck()
+	if config.BotUserAgentsPath != "" {
+		f, err := os.Open(config.BotUserAgentsPath)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		userAgents, err = bots.LoadUserAgentCheck(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	checks := []bots.Check{userAgents}
+	if config.BotNetworksPath != "" {
+		f, err := os.Open(config.BotNetworksPath)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		networks, err := bots.LoadNetworkCheck(f)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, networks)
+	}
+
+	checks = append(checks,
+		bots.AcceptLanguageCheck,
+		bots.NewEventRateCheck(ratelimit.Limit{Requests: config.BotEventRate, Per: time.Minute}),
+	)
+
+	return bots.NewDetector(checks...), nil
+}
+
 func gracefulShutdown(app *internal.App, server *http.Server) chan error {
 	shutdownError := make(chan error)
 	go func() {
@@ -131,7 +267,16 @@ func gracefulShutdown(app *internal.App, server *http.Server) chan error {
 
 		app.Logger.Info("Waiting for background tasks...", "address", server.Addr)
 
+		// Nothing can be enqueued once the server has stopped, so the
+		// ingestion workers can finish inserting what's left.
+		app.Ingest.Close()
 		app.WG.Wait()
+
+		err = app.SaveRejections()
+		if err != nil {
+			app.Logger.Error("Unable to save rejected requests", "error", err.Error())
+		}
+
 		shutdownError <- nil
 	}()
 
@@ -139,6 +284,17 @@ func gracefulShutdown(app *internal.App, server *http.Server) chan error {
 }
 
 func startBackgroundProcesses(app *internal.App) {
+	app.StartProcess("rejected request saving", func() []any {
+		for {
+			time.Sleep(internal.RejectionSaveInterval)
+
+			err := app.SaveRejections()
+			if err != nil {
+				app.Logger.Error("Unable to save rejected requests", "error", err.Error())
+			}
+		}
+	})
+
 	app.StartProcess("session cleanup", func() []any {
 		for {
 			time.Sleep(24 * time.Hour)
@@ -154,4 +310,22 @@ func startBackgroundProcesses(app *internal.App) {
 			})
 		}
 	})
+
+	app.StartProcess("visitor salt rotation", func() []any {
+		for {
+			now := time.Now().UTC()
+			tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+			time.Sleep(tomorrow.Sub(now))
+
+			app.InBackground("visitor salt rotation", func() []any {
+				count, err := app.RotateVisitorSalt(time.Now())
+				if err != nil {
+					app.Logger.Error("Unable to rotate visitor salt", "error", err.Error())
+					return []any{}
+				}
+
+				return []any{"deleted", count}
+			})
+		}
+	})
 }
@@ -17,3 +17,7 @@ require github.com/tomasen/realip v0.0.0-20180522021738-f0c99a92ddce
 require github.com/gorilla/securecookie v1.1.2
 
 require github.com/mileusna/useragent v1.3.5
+
+require github.com/oschwald/maxminddb-golang v1.13.1
+
+require golang.org/x/sys v0.31.0 // indirect
@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+
+	"github.com/robyparr/event-horizon/internal/models"
+)
+
+const apiKeyPrefix = "eh_"
+
+// CreateAPIKey generates a new API key for the user and returns it. Only its
+// hash is stored, so the key can't be shown again afterwards.
+func (app *App) CreateAPIKey(userID int64, name string) (string, error) {
+	key := apiKeyPrefix + rand.Text()
+	k := models.APIKey{
+		UserID:      userID,
+		Name:        name,
+		Token:       HashAPIKey(key),
+		TokenPrefix: key[:len(apiKeyPrefix)+6],
+	}
+
+	err := app.Repos.APIKeys.Insert(&k)
+	if err != nil {
+		return "", fmt.Errorf("[CreateAPIKey] %w", err)
+	}
+
+	return key, nil
+}
+
+func HashAPIKey(key string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(key)))
+}
+
+// SetAPIKey makes the key's owner the current user for the rest of the
+// request.
+func (app *App) SetAPIKey(r *http.Request, k models.APIKey) *http.Request {
+	ctx := context.WithValue(r.Context(), ctxKeyCurrentUser, k.User)
+	return r.WithContext(ctx)
+}
@@ -3,12 +3,16 @@ package internal
 import (
 	"html/template"
 	"log/slog"
+	"net/netip"
 	"sync"
 	"time"
 
 	"github.com/go-playground/form/v4"
 	"github.com/gorilla/securecookie"
+	"github.com/robyparr/event-horizon/internal/bots"
 	"github.com/robyparr/event-horizon/internal/models"
+	"github.com/robyparr/event-horizon/internal/realtime"
+	"github.com/robyparr/event-horizon/internal/tracking"
 )
 
 const (
@@ -17,14 +21,23 @@ const (
 )
 
 type App struct {
-	Logger       *slog.Logger
-	Repos        *models.Repos
-	Views        map[string]*template.Template
-	FormDecoder  *form.Decoder
-	SecureCookie *securecookie.SecureCookie
-	Config       Config
-	StartedAt    time.Time
-	WG           sync.WaitGroup
+	Logger          *slog.Logger
+	Repos           *models.Repos
+	Views           map[string]*template.Template
+	FormDecoder     *form.Decoder
+	SecureCookie    *securecookie.SecureCookie
+	ReferrerSources *tracking.Sources
+	GeoDB           *tracking.GeoDB
+	Realtime        *realtime.Broker
+	Ingest          *EventQueue
+	RateLimits      *RateLimits
+	Rejections      *Rejections
+	Bots            *bots.Detector
+	Config          Config
+	StartedAt       time.Time
+	WG              sync.WaitGroup
+
+	visitorSalt visitorSalt
 }
 
 type Config struct {
@@ -32,6 +45,44 @@ type Config struct {
 	Host        string
 	DatabaseURL string
 	SkipTLS     bool
+
+	// ReferrerSourcesPath replaces the built-in list of known referrer
+	// sources with a JSON file in the same format.
+	ReferrerSourcesPath string
+
+	// GeoDBPath is an optional MaxMind or DB-IP compatible .mmdb database
+	// that visitors' locations are looked up in.
+	GeoDBPath string
+
+	// IngestQueueSize is how many events the API can accept ahead of the
+	// database before it starts turning them away.
+	IngestQueueSize int
+
+	// IngestWorkers insert queued events in batches of up to IngestBatchSize,
+	// or whatever has queued up every IngestFlushInterval.
+	IngestWorkers       int
+	IngestBatchSize     int
+	IngestFlushInterval time.Duration
+
+	// RateLimitPerToken and RateLimitPerIP are how many ingestion API
+	// requests a site's token and an IP address may each make a minute.
+	// Zero allows any number.
+	RateLimitPerToken int
+	RateLimitPerIP    int
+
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For and
+	// X-Real-Ip headers are believed when working out a client's IP address.
+	TrustedProxies []netip.Prefix
+
+	// BotUserAgentsPath replaces the built-in list of bot user agent patterns,
+	// and BotNetworksPath is an optional list of data center IP ranges whose
+	// browsers are treated as bots.
+	BotUserAgentsPath string
+	BotNetworksPath   string
+
+	// BotEventRate is how many events a minute a browser may send a site
+	// before it's treated as a bot. Zero allows any number.
+	BotEventRate int
 }
 
 func (c Config) IsProductionEnv() bool {
@@ -0,0 +1,70 @@
+// Package bots decides whether requests to the events API come from bots,
+// crawlers and scrapers rather than people, giving the reason for the ones it
+// filters out so they can be counted and audited.
+package bots
+
+import (
+	"net/http"
+	"net/netip"
+	"time"
+)
+
+const (
+	ReasonUserAgent      = "user_agent"
+	ReasonDataCenter     = "data_center"
+	ReasonAcceptLanguage = "accept_language"
+	ReasonEventRate      = "event_rate"
+)
+
+// Reasons are every reason a request can be filtered out for.
+var Reasons = []string{ReasonUserAgent, ReasonDataCenter, ReasonAcceptLanguage, ReasonEventRate}
+
+// Request is what checks are told about a request for a site's events.
+type Request struct {
+	SiteID    int64
+	IP        netip.Addr
+	UserAgent string
+	Header    http.Header
+	Time      time.Time
+}
+
+// fromBrowser reports whether the request was sent by a page in a browser,
+// headless or not, rather than by a site's servers reporting their own events.
+// Browsers always send an Origin with cross-origin requests.
+func (r Request) fromBrowser() bool {
+	return r.Header.Get("Origin") != ""
+}
+
+// A Check returns the reason a request looks like it came from a bot, or ""
+// when it doesn't.
+type Check interface {
+	Check(req Request) string
+}
+
+// CheckFunc adapts a function to a Check.
+type CheckFunc func(req Request) string
+
+func (f CheckFunc) Check(req Request) string {
+	return f(req)
+}
+
+// Detector runs its checks in order, stopping at the first that flags a
+// request.
+type Detector struct {
+	checks []Check
+}
+
+func NewDetector(checks ...Check) *Detector {
+	return &Detector{checks: checks}
+}
+
+// Detect returns the reason req is filtered out, if any.
+func (d *Detector) Detect(req Request) string {
+	for _, check := range d.checks {
+		if reason := check.Check(req); reason != "" {
+			return reason
+		}
+	}
+
+	return ""
+}
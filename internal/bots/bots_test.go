@@ -0,0 +1,124 @@
+package bots
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+	"github.com/robyparr/event-horizon/internal/ratelimit"
+)
+
+const firefox = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:131.0) Gecko/20100101 Firefox/131.0"
+
+func browserRequest(ua string) Request {
+	return Request{
+		SiteID:    1,
+		IP:        netip.MustParseAddr("203.0.113.7"),
+		UserAgent: ua,
+		Header:    http.Header{"Origin": {"https://example.com"}, "Accept-Language": {"en-CA"}},
+		Time:      time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestDefaultUserAgentCheck(t *testing.T) {
+	tests := []struct {
+		ua   string
+		want string
+	}{
+		{ua: firefox, want: ""},
+		{ua: "Go-http-client/1.1", want: ""},
+		{ua: "", want: ReasonUserAgent},
+		{ua: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/129.0.0.0 Safari/537.36", want: ReasonUserAgent},
+		{ua: "Mozilla/5.0 (compatible; UptimeRobot/2.0; http://www.uptimerobot.com/)", want: ReasonUserAgent},
+		{ua: "Mozilla/5.0 (Linux; Android 11; moto g power (2022)) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/109.0.0.0 Mobile Safari/537.36 Chrome-Lighthouse", want: ReasonUserAgent},
+		{ua: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", want: ReasonUserAgent},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, DefaultUserAgentCheck().Check(browserRequest(tc.ua)), tc.want)
+	}
+}
+
+func TestLoadUserAgentCheck(t *testing.T) {
+	check, err := LoadUserAgentCheck(strings.NewReader("# Our own load tester\n\nloadtest/\\d+\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, check.Check(browserRequest("LoadTest/2 (+https://example.com)")), ReasonUserAgent)
+	assert.Equal(t, check.Check(browserRequest("Mozilla/5.0 HeadlessChrome/129.0")), "")
+
+	_, err = LoadUserAgentCheck(strings.NewReader("unclosed(\n"))
+	assert.Equal(t, err != nil, true)
+}
+
+func TestNetworkCheck(t *testing.T) {
+	check, err := LoadNetworkCheck(strings.NewReader("# Example cloud\n203.0.113.0/24 # region a\n\n2001:db8::/32\n198.51.100.7\n"))
+	assert.Nil(t, err)
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{ip: "203.0.113.200", want: ReasonDataCenter},
+		{ip: "::ffff:203.0.113.1", want: ReasonDataCenter},
+		{ip: "2001:db8:5::1", want: ReasonDataCenter},
+		{ip: "198.51.100.7", want: ReasonDataCenter},
+		{ip: "198.51.100.8", want: ""},
+	}
+
+	for _, tc := range tests {
+		req := browserRequest(firefox)
+		req.IP = netip.MustParseAddr(tc.ip)
+		assert.Equal(t, check.Check(req), tc.want)
+	}
+
+	// Sites sending their own events from servers aren't bots.
+	req := browserRequest("Go-http-client/1.1")
+	req.Header.Del("Origin")
+	assert.Equal(t, check.Check(req), "")
+
+	_, err = LoadNetworkCheck(strings.NewReader("203.0.113.0/24\nnot-a-network\n"))
+	assert.StringContains(t, err.Error(), "line 2")
+}
+
+func TestAcceptLanguageCheck(t *testing.T) {
+	req := browserRequest(firefox)
+	assert.Equal(t, AcceptLanguageCheck.Check(req), "")
+
+	req.Header.Del("Accept-Language")
+	assert.Equal(t, AcceptLanguageCheck.Check(req), ReasonAcceptLanguage)
+
+	req.Header.Del("Origin")
+	assert.Equal(t, AcceptLanguageCheck.Check(req), "")
+}
+
+func TestEventRateCheck(t *testing.T) {
+	check := NewEventRateCheck(ratelimit.Limit{Requests: 2, Per: time.Minute})
+	req := browserRequest(firefox)
+
+	assert.Equal(t, check.Check(req), "")
+	assert.Equal(t, check.Check(req), "")
+	assert.Equal(t, check.Check(req), ReasonEventRate)
+
+	other := browserRequest(firefox)
+	other.SiteID = 2
+	assert.Equal(t, check.Check(other), "")
+
+	req.Time = req.Time.Add(time.Minute)
+	assert.Equal(t, check.Check(req), "")
+}
+
+func TestDetector(t *testing.T) {
+	d := NewDetector(DefaultUserAgentCheck(), AcceptLanguageCheck)
+
+	assert.Equal(t, d.Detect(browserRequest(firefox)), "")
+	assert.Equal(t, d.Detect(browserRequest("")), ReasonUserAgent)
+
+	noLanguage := browserRequest(firefox)
+	noLanguage.Header.Del("Accept-Language")
+	assert.Equal(t, d.Detect(noLanguage), ReasonAcceptLanguage)
+
+	noLanguage.UserAgent = ""
+	assert.Equal(t, d.Detect(noLanguage), ReasonUserAgent)
+}
@@ -0,0 +1,40 @@
+package bots
+
+import (
+	"fmt"
+
+	"github.com/robyparr/event-horizon/internal/ratelimit"
+)
+
+// AcceptLanguageCheck flags browsers that don't send an Accept-Language
+// header. Every browser people use does, but many automated ones don't.
+var AcceptLanguageCheck = CheckFunc(func(req Request) string {
+	if req.fromBrowser() && req.Header.Get("Accept-Language") == "" {
+		return ReasonAcceptLanguage
+	}
+
+	return ""
+})
+
+// EventRateCheck flags browsers sending a site events faster than a person
+// browsing it could.
+type EventRateCheck struct {
+	limiter *ratelimit.Limiter
+}
+
+func NewEventRateCheck(limit ratelimit.Limit) *EventRateCheck {
+	return &EventRateCheck{limiter: ratelimit.NewLimiter(limit)}
+}
+
+func (c *EventRateCheck) Check(req Request) string {
+	if !req.fromBrowser() {
+		return ""
+	}
+
+	key := fmt.Sprintf("%d|%s|%s", req.SiteID, req.IP, req.UserAgent)
+	if ok, _ := c.limiter.Allow(key, req.Time); !ok {
+		return ReasonEventRate
+	}
+
+	return ""
+}
@@ -0,0 +1,71 @@
+package bots
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+)
+
+// NetworkCheck flags browsers on known data center networks, where people
+// rarely browse from but scrapers and headless browsers usually run.
+type NetworkCheck struct {
+	prefixes []netip.Prefix
+}
+
+// LoadNetworkCheck reads one CIDR range or IP address per line. Anything
+// after a # is a comment.
+func LoadNetworkCheck(r io.Reader) (*NetworkCheck, error) {
+	check := &NetworkCheck{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		prefix, err := parsePrefix(line)
+		if err != nil {
+			return nil, fmt.Errorf("[bots.LoadNetworkCheck] line %d: %w", n, err)
+		}
+		check.prefixes = append(check.prefixes, prefix)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("[bots.LoadNetworkCheck] %w", err)
+	}
+
+	return check, nil
+}
+
+func (c *NetworkCheck) Check(req Request) string {
+	if !req.fromBrowser() || !req.IP.IsValid() {
+		return ""
+	}
+
+	ip := req.IP.Unmap()
+	for _, prefix := range c.prefixes {
+		if prefix.Contains(ip) {
+			return ReasonDataCenter
+		}
+	}
+
+	return ""
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+
+	return addr.Prefix(addr.BitLen())
+}
@@ -0,0 +1,76 @@
+package bots
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/mileusna/useragent"
+)
+
+//go:embed user_agents.txt
+var defaultUserAgentPatterns []byte
+
+// DefaultUserAgentCheck uses the built-in list of bot user agent patterns,
+// used unless a replacement list is configured.
+var DefaultUserAgentCheck = sync.OnceValue(func() *UserAgentCheck {
+	check, err := LoadUserAgentCheck(bytes.NewReader(defaultUserAgentPatterns))
+	if err != nil {
+		panic(err)
+	}
+
+	return check
+})
+
+// UserAgentCheck flags user agents that are blank, known to the useragent
+// package as bots, or match any of its patterns.
+type UserAgentCheck struct {
+	patterns *regexp.Regexp
+}
+
+// LoadUserAgentCheck reads one case-insensitive regular expression per line.
+// Blank lines and lines starting with # are ignored.
+func LoadUserAgentCheck(r io.Reader) (*UserAgentCheck, error) {
+	var patterns []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if _, err := regexp.Compile(line); err != nil {
+			return nil, fmt.Errorf("[bots.LoadUserAgentCheck] %w", err)
+		}
+		patterns = append(patterns, "(?:"+line+")")
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("[bots.LoadUserAgentCheck] %w", err)
+	}
+
+	check := &UserAgentCheck{}
+	if len(patterns) > 0 {
+		check.patterns = regexp.MustCompile("(?i)" + strings.Join(patterns, "|"))
+	}
+
+	return check, nil
+}
+
+func (c *UserAgentCheck) Check(req Request) string {
+	ua := strings.TrimSpace(req.UserAgent)
+	if ua == "" || useragent.Parse(ua).Bot {
+		return ReasonUserAgent
+	}
+
+	if c.patterns != nil && c.patterns.MatchString(ua) {
+		return ReasonUserAgent
+	}
+
+	return ""
+}
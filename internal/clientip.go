@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// DefaultTrustedProxies are the networks reverse proxies usually run on:
+// loopback and private addresses.
+var DefaultTrustedProxies = []netip.Prefix{
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("fc00::/7"),
+}
+
+// ParseTrustedProxies reads a comma separated list of IP addresses and CIDR
+// ranges.
+func ParseTrustedProxies(text string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for value := range strings.SplitSeq(text, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(value)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", value)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+
+		proxies = append(proxies, prefix.Masked())
+	}
+
+	return proxies, nil
+}
+
+// ClientIP is the IP address of the client that sent r. X-Forwarded-For and
+// X-Real-Ip are only read when the request came from a trusted proxy, since
+// anyone else can set them to anything. The client is the last forwarded
+// address that isn't a trusted proxy, as the ones before it were sent by the
+// client itself.
+func (app *App) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+
+	addr = addr.Unmap()
+	if !app.trustsProxy(addr) {
+		return addr.String()
+	}
+
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 {
+		realIP, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-Ip")))
+		if err != nil {
+			return addr.String()
+		}
+
+		return realIP.Unmap().String()
+	}
+
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+
+		addr = hop.Unmap()
+		if !app.trustsProxy(addr) {
+			break
+		}
+	}
+
+	return addr.String()
+}
+
+func (app *App) trustsProxy(addr netip.Addr) bool {
+	for _, prefix := range app.Config.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies(" 203.0.113.7, 10.1.2.3/8,,2001:db8::1/32 ")
+	assert.Nil(t, err)
+	assert.Equal(t, len(proxies), 3)
+	assert.Equal(t, proxies[0].String(), "203.0.113.7/32")
+	assert.Equal(t, proxies[1].String(), "10.0.0.0/8")
+	assert.Equal(t, proxies[2].String(), "2001:db8::/32")
+
+	_, err = ParseTrustedProxies("10.0.0.0/8, office")
+	assert.NotNil(t, err)
+}
+
+func TestClientIP(t *testing.T) {
+	app := &App{Config: Config{TrustedProxies: DefaultTrustedProxies}}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    http.Header
+		want       string
+	}{
+		{name: "No proxy", remoteAddr: "203.0.113.7:1234", want: "203.0.113.7"},
+		{
+			name:       "Forwarded headers from an untrusted client",
+			remoteAddr: "203.0.113.7:1234",
+			headers:    http.Header{"X-Forwarded-For": {"198.51.100.1"}, "X-Real-Ip": {"198.51.100.2"}},
+			want:       "203.0.113.7",
+		},
+		{name: "Trusted proxy", remoteAddr: "127.0.0.1:1234", headers: http.Header{"X-Forwarded-For": {"203.0.113.7"}}, want: "203.0.113.7"},
+		{
+			name:       "Spoofed forwarded address",
+			remoteAddr: "127.0.0.1:1234",
+			headers:    http.Header{"X-Forwarded-For": {"198.51.100.1, 203.0.113.7, 10.0.0.1"}},
+			want:       "203.0.113.7",
+		},
+		{name: "Only trusted proxies", remoteAddr: "127.0.0.1:1234", headers: http.Header{"X-Forwarded-For": {"10.0.0.2, 10.0.0.1"}}, want: "10.0.0.2"},
+		{name: "Garbage forwarded address", remoteAddr: "127.0.0.1:1234", headers: http.Header{"X-Forwarded-For": {"not-an-ip"}}, want: "127.0.0.1"},
+		{name: "Real IP", remoteAddr: "[::1]:1234", headers: http.Header{"X-Real-Ip": {"203.0.113.7"}}, want: "203.0.113.7"},
+		{name: "Garbage real IP", remoteAddr: "127.0.0.1:1234", headers: http.Header{"X-Real-Ip": {"a very long header"}}, want: "127.0.0.1"},
+		{name: "Mapped IPv4", remoteAddr: "[::ffff:203.0.113.7]:1234", want: "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/events", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for key, values := range tt.headers {
+				r.Header[key] = values
+			}
+
+			assert.Equal(t, app.ClientIP(r), tt.want)
+		})
+	}
+
+	t.Run("No trusted proxies", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/api/events", nil)
+		r.RemoteAddr = "127.0.0.1:1234"
+		r.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+		assert.Equal(t, (&App{}).ClientIP(r), "127.0.0.1")
+	})
+}
@@ -1,18 +1,89 @@
 package handlers
 
 import (
+	"bufio"
+	"bytes"
 	"cmp"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/mileusna/useragent"
 	"github.com/robyparr/event-horizon/internal"
 	"github.com/robyparr/event-horizon/internal/models"
+	"github.com/robyparr/event-horizon/internal/tracking"
+	"github.com/robyparr/event-horizon/internal/validator"
 )
 
-func apiPreflightHandler(_ *internal.App) http.Handler {
+const (
+	maxEventBytes     = 64 * 1024
+	maxBatchEvents    = 5000
+	maxBatchBytes     = 10 * 1024 * 1024
+	maxBatchLineBytes = 1024 * 1024
+)
+
+const (
+	maxActionChars           = 100
+	maxEventCount            = 10000
+	maxEventProperties       = 25
+	maxEventPropertyKeyChars = 64
+	maxEventPropertyChars    = 255
+)
+
+const (
+	batchStatusCreated = "created"
+	batchStatusInvalid = "invalid"
+)
+
+var ndjsonContentTypes = []string{"application/x-ndjson", "application/ndjson"}
+
+const errHostnameNotAllowed = "events from this hostname aren't allowed for this site"
+
+var errTooManyBatchEvents = fmt.Errorf("a batch may contain at most %d events", maxBatchEvents)
+
+// actionRX keeps action names to a single word that's safe to use in URLs,
+// filters and funnel steps, e.g. "signup", "add_to_cart" or "video:play".
+var actionRX = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:/-]*$`)
+
+// apiError is the body of every error response from the events API, so SDKs
+// can show what went wrong. Errors maps any invalid fields to their problems.
+type apiError struct {
+	Error  string            `json:"error"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+type eventPayload struct {
+	Action              string         `json:"action"`
+	Count               int            `json:"count"`
+	Referrer            string         `json:"referrer"`
+	URL                 string         `json:"url"`
+	Hostname            string         `json:"hostname"`
+	Path                string         `json:"path"`
+	UTM                 tracking.UTM   `json:"utm"`
+	Props               map[string]any `json:"props"`
+	page                tracking.Page
+	utm                 tracking.UTM
+	referrer            tracking.Referrer
+	validator.Validator `json:"-"`
+}
+
+type batchEventResult struct {
+	Index  int               `json:"index"`
+	Status string            `json:"status"`
+	ID     int64             `json:"id,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+func apiPreflightHandler(app *internal.App) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		requestMethod := r.Header.Get("Access-Control-Request-Method")
 		if requestMethod == "" {
@@ -25,52 +96,379 @@ func apiPreflightHandler(_ *internal.App) http.Handler {
 			return
 		}
 
+		// Preflight requests don't carry the site's token, so the origin is only
+		// let through if some site accepts it. The request that follows is
+		// checked against its own site's allowed hostnames.
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || u.Hostname() == "" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			ok, err := app.Repos.Sites.AnyAllowsHostname(u.Hostname())
+			if err != nil {
+				app.ServerError(w, r, err)
+				return
+			}
+
+			if !ok {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+
 		w.WriteHeader(http.StatusOK)
 	})
 }
 
 func apiCreateEventHandler(app *internal.App) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ua := useragent.Parse(r.UserAgent())
-		if ua.Bot {
-			w.WriteHeader(http.StatusForbidden)
+		var eventData eventPayload
+		err := decodeEvent(http.MaxBytesReader(w, r.Body, maxEventBytes), &eventData)
+		if err != nil {
+			app.RenderJSON(w, r, bodyErrorStatus(err), apiError{Error: err.Error()})
+			return
+		}
+
+		eventData.validate()
+		if !eventData.Valid() {
+			app.RenderJSON(w, r, http.StatusUnprocessableEntity, apiError{Error: "the event is invalid", Errors: eventData.FieldErrors})
 			return
 		}
 
-		var eventData struct {
-			Action   string `json:"action"`
-			Count    int    `json:"count"`
-			Referrer string `json:"referrer"`
+		site := app.MustGetCurrentSite(r)
+		if !allowsPageHostname(r, site, eventData.page.Hostname) {
+			app.RenderJSON(w, r, http.StatusForbidden, apiError{Error: errHostnameNotAllowed})
+			return
 		}
 
-		err := json.NewDecoder(r.Body).Decode(&eventData)
+		client, err := readEventClient(app, r, site, false)
 		if err != nil {
 			app.ServerError(w, r, err)
 			return
 		}
 
-		referrerURL, err := url.Parse(eventData.Referrer)
+		event := buildEvent(site, client, app.ReferrerSources, eventData)
+
+		// Events are inserted in the background so a slow database doesn't
+		// slow down the pages sending them. When it falls too far behind,
+		// clients are asked to back off.
+		err = app.Ingest.Enqueue(&event)
 		if err != nil {
-			referrerURL = &url.URL{}
+			w.Header().Set("Retry-After", "1")
+			app.RenderJSON(w, r, http.StatusServiceUnavailable, apiError{Error: "unable to accept events right now, please retry"})
+			return
 		}
 
-		site := app.MustGetCurrentSite(r)
-		event := models.Event{
-			SiteID:     site.ID,
-			Action:     eventData.Action,
-			Count:      eventData.Count,
-			DeviceType: cmp.Or(ua.Device, "Unknown"),
-			OS:         cmp.Or(ua.OS, "Unknown"),
-			Browser:    cmp.Or(ua.Name, "Unknown"),
-			Referrer:   sql.NullString{Valid: referrerURL.Host != "", String: referrerURL.Host},
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+func apiCreateEventsBatchHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBatchBytes)
+		items, err := readBatchItems(r)
+		if err != nil {
+			app.RenderJSON(w, r, bodyErrorStatus(err), apiError{Error: err.Error()})
+			return
+		}
+
+		if len(items) == 0 {
+			app.RenderJSON(w, r, http.StatusBadRequest, apiError{Error: "no events were provided"})
+			return
 		}
 
-		err = app.Repos.Events.Insert(&event)
+		site := app.MustGetCurrentSite(r)
+		client, err := readEventClient(app, r, site, true)
 		if err != nil {
 			app.ServerError(w, r, err)
 			return
 		}
 
-		w.WriteHeader(http.StatusCreated)
+		results := make([]batchEventResult, len(items))
+		events := make([]*models.Event, 0, len(items))
+		eventIndexes := make([]int, 0, len(items))
+
+		for i, item := range items {
+			results[i] = batchEventResult{Index: i, Status: batchStatusInvalid}
+
+			var eventData eventPayload
+			err := decodeEvent(bytes.NewReader(item), &eventData)
+			if err != nil {
+				results[i].Errors = map[string]string{"event": err.Error()}
+				continue
+			}
+
+			eventData.validate()
+			if !eventData.Valid() {
+				results[i].Errors = eventData.FieldErrors
+				continue
+			}
+
+			if !allowsPageHostname(r, site, eventData.page.Hostname) {
+				results[i].Errors = map[string]string{"hostname": "This hostname isn't allowed for this site"}
+				continue
+			}
+
+			event := buildEvent(site, client, app.ReferrerSources, eventData)
+			events = append(events, &event)
+			eventIndexes = append(eventIndexes, i)
+		}
+
+		if len(events) > 0 {
+			err = app.Repos.Events.InsertMany(events)
+			if err != nil {
+				app.ServerError(w, r, err)
+				return
+			}
+			app.Realtime.Publish(site.ID)
+		}
+
+		for i, event := range events {
+			result := &results[eventIndexes[i]]
+			result.Status = batchStatusCreated
+			result.ID = event.ID
+		}
+
+		status := http.StatusCreated
+		switch {
+		case len(events) == 0:
+			status = http.StatusUnprocessableEntity
+		case len(events) < len(items):
+			status = http.StatusMultiStatus
+		}
+
+		app.RenderJSON(w, r, status, map[string]any{
+			"created": len(events),
+			"failed":  len(items) - len(events),
+			"results": results,
+		})
 	})
 }
+
+// readBatchItems splits the request body into raw events so each one can be
+// decoded and validated on its own. Bodies are read as a JSON array unless
+// they're sent as newline delimited JSON.
+func readBatchItems(r *http.Request) ([]json.RawMessage, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !slices.Contains(ndjsonContentTypes, mediaType) {
+		var items []json.RawMessage
+		err := json.NewDecoder(r.Body).Decode(&items)
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			switch {
+			case errors.Is(err, io.EOF):
+				return items, nil
+			case errors.As(err, &maxBytesError):
+				return nil, bodyTooLargeError{limit: maxBytesError.Limit}
+			}
+
+			return nil, errors.New("body must be a JSON array of events")
+		}
+
+		if len(items) > maxBatchEvents {
+			return nil, errTooManyBatchEvents
+		}
+
+		return items, nil
+	}
+
+	var items []json.RawMessage
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBatchLineBytes)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		if len(items) == maxBatchEvents {
+			return nil, errTooManyBatchEvents
+		}
+
+		items = append(items, json.RawMessage(append([]byte(nil), line...)))
+	}
+
+	if err := scanner.Err(); err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return nil, bodyTooLargeError{limit: maxBytesError.Limit}
+		}
+
+		return nil, fmt.Errorf("unable to read events: %w", err)
+	}
+
+	return items, nil
+}
+
+// decodeEvent reads a single event from r, describing what's wrong with the
+// JSON in terms an SDK author can act on.
+func decodeEvent(r io.Reader, eventData *eventPayload) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(eventData)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var typeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &maxBytesError):
+			return bodyTooLargeError{limit: maxBytesError.Limit}
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("event contains badly-formed JSON (at character %d)", syntaxError.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("event contains badly-formed JSON")
+		case errors.As(err, &typeError):
+			if typeError.Field != "" {
+				return fmt.Errorf("event contains the wrong type of value for the %q field", typeError.Field)
+			}
+			return errors.New("event must be a JSON object")
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return fmt.Errorf("event contains unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+		default:
+			return fmt.Errorf("unable to read event: %w", err)
+		}
+	}
+
+	if dec.More() {
+		return errors.New("body must only contain a single event")
+	}
+
+	return nil
+}
+
+// bodyTooLargeError is returned when a request's body is cut off by
+// http.MaxBytesReader.
+type bodyTooLargeError struct {
+	limit int64
+}
+
+func (e bodyTooLargeError) Error() string {
+	return fmt.Sprintf("body must not be larger than %d bytes", e.limit)
+}
+
+// bodyErrorStatus is the status for an error reading a request's events.
+func bodyErrorStatus(err error) int {
+	if errors.As(err, new(bodyTooLargeError)) || errors.Is(err, errTooManyBatchEvents) {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	return http.StatusBadRequest
+}
+
+func (p *eventPayload) validate() {
+	p.CheckField(validator.NotBlank(p.Action), "action", "This field cannot be blank")
+	p.CheckField(validator.MaxChars(p.Action, maxActionChars), "action", fmt.Sprintf("This field cannot be more than %d characters long", maxActionChars))
+	p.CheckField(validator.Matches(p.Action, actionRX), "action", "This field must start with a letter or number and only contain letters, numbers and _ . : / -")
+	p.CheckField(p.Count >= 0, "count", "This field cannot be negative")
+	p.CheckField(p.Count <= maxEventCount, "count", fmt.Sprintf("This field cannot be more than %d", maxEventCount))
+	p.CheckField(len(p.Props) <= maxEventProperties, "props", fmt.Sprintf("No more than %d properties are allowed", maxEventProperties))
+
+	for key, value := range p.Props {
+		p.CheckField(validator.NotBlank(key), "props", "Property names cannot be blank")
+		p.CheckField(validator.MaxChars(key, maxEventPropertyKeyChars), "props", fmt.Sprintf("Property names cannot be more than %d characters long", maxEventPropertyKeyChars))
+
+		switch v := value.(type) {
+		case string:
+			p.CheckField(validator.MaxChars(v, maxEventPropertyChars), "props", fmt.Sprintf("Property values cannot be more than %d characters long", maxEventPropertyChars))
+		case float64, bool:
+		default:
+			p.AddFieldError("props", "Property values must be strings, numbers or booleans")
+		}
+	}
+
+	page, err := tracking.ParsePage(p.URL, p.Hostname, p.Path)
+	p.CheckField(err == nil, "url", "This field must be a valid URL")
+	p.page = page
+	// Parameters on the page itself win over the ones the script remembered
+	// from the visitor's landing page.
+	p.utm = tracking.ParseUTM(cmp.Or(p.URL, p.Path))
+	if p.utm.IsZero() {
+		p.utm = p.UTM.Normalize()
+	}
+
+	// A referrer that can't be parsed is dropped rather than losing the event,
+	// as are links between pages of the same site.
+	referrer, err := tracking.ParseReferrer(p.Referrer)
+	if err == nil && !referrer.IsInternal(p.page) {
+		p.referrer = referrer
+	}
+}
+
+// allowsPageHostname reports whether site accepts an event from a page on
+// hostname. Without a hostname, the request's Origin or Referer has already
+// been checked by checkOrigin. When there's neither, a site that only allows
+// some hostnames can't tell where the event came from and turns it away.
+func allowsPageHostname(r *http.Request, site *models.Site, hostname string) bool {
+	if _, found := requestHostname(r); found && hostname == "" {
+		return true
+	}
+
+	return site.AllowsHostname(hostname)
+}
+
+// eventClient is what's known about the client that sent a request's events.
+// The client's IP address is only used to derive these and is never stored.
+type eventClient struct {
+	ua        useragent.UserAgent
+	visitorID string
+	location  tracking.Location
+}
+
+// readEventClient only gives the client a visitor ID when it's a browser
+// sending a single event, found by its Origin or Referer. Batches and calls
+// from servers send events for many people from one IP address and user
+// agent, so they'd all be counted as the same visitor.
+func readEventClient(app *internal.App, r *http.Request, site *models.Site, batch bool) (eventClient, error) {
+	ip := app.ClientIP(r)
+	client := eventClient{ua: useragent.Parse(r.UserAgent()), location: app.GeoDB.Lookup(ip)}
+	if _, fromBrowser := requestHostname(r); batch || !fromBrowser {
+		return client, nil
+	}
+
+	visitorID, err := app.VisitorID(site.ID, ip, r.UserAgent())
+	if err != nil {
+		return eventClient{}, err
+	}
+
+	client.visitorID = visitorID
+	return client, nil
+}
+
+func buildEvent(site *models.Site, client eventClient, sources *tracking.Sources, eventData eventPayload) models.Event {
+	referrer := eventData.referrer
+	source, _ := sources.Lookup(referrer.Host)
+
+	return models.Event{
+		SiteID:         site.ID,
+		CreatedAt:      time.Now().UTC(),
+		Action:         eventData.Action,
+		Count:          eventData.Count,
+		DeviceType:     cmp.Or(client.ua.Device, "Unknown"),
+		OS:             cmp.Or(client.ua.OS, "Unknown"),
+		Browser:        cmp.Or(client.ua.Name, "Unknown"),
+		Referrer:       sql.NullString{Valid: referrer.Host != "", String: referrer.Host},
+		ReferrerURL:    referrer.URL,
+		ReferrerSource: source.Name,
+		Channel:        sources.Channel(referrer, eventData.utm),
+		Country:        client.location.Country,
+		Region:         client.location.Region,
+		City:           client.location.City,
+		Properties:     eventData.Props,
+		VisitorID:      sql.NullString{Valid: client.visitorID != "", String: client.visitorID},
+		Hostname:       eventData.page.Hostname,
+		Path:           eventData.page.Path,
+		UTMSource:      eventData.utm.Source,
+		UTMMedium:      eventData.utm.Medium,
+		UTMCampaign:    eventData.utm.Campaign,
+		UTMTerm:        eventData.utm.Term,
+		UTMContent:     eventData.utm.Content,
+	}
+}
@@ -0,0 +1,732 @@
+package handlers_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal"
+	"github.com/robyparr/event-horizon/internal/assert"
+	"github.com/robyparr/event-horizon/internal/bots"
+	"github.com/robyparr/event-horizon/internal/models"
+	"github.com/robyparr/event-horizon/internal/models/mocks"
+	"github.com/robyparr/event-horizon/internal/ratelimit"
+)
+
+func TestAPICreateEvent(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	site := models.Site{UserID: 1, Name: "Test", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+
+	tests := []struct {
+		name      string
+		body      string
+		wantCode  int
+		wantBody  string
+		wantProps models.EventProperties
+	}{
+		{
+			name:      "With properties",
+			body:      `{"action": "signup", "count": 1, "props": {"plan": "pro", "seats": 3, "trial": true}}`,
+			wantCode:  http.StatusAccepted,
+			wantProps: models.EventProperties{"plan": "pro", "seats": float64(3), "trial": true},
+		},
+		{
+			name:     "Nested property value",
+			body:     `{"action": "signup", "props": {"plan": {"tier": "pro"}}}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Property values must be strings, numbers or booleans",
+		},
+		{
+			name:     "Blank action",
+			body:     `{"action": " "}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `"action":"This field cannot be blank"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			eventRepo := app.Repos.Events.(*mocks.EventRepo)
+			eventRepo.Events = make(map[int64]models.Event)
+
+			code, _, body := ts.postAPI(t, "/api/events", site.Token, "application/json", tc.body)
+			assert.Equal(t, code, tc.wantCode)
+			ts.flushEvents()
+
+			if tc.wantBody != "" {
+				assert.StringContains(t, body, tc.wantBody)
+			}
+
+			if tc.wantProps != nil {
+				assert.Equal(t, len(eventRepo.Events), 1)
+				for key, value := range tc.wantProps {
+					assert.Equal(t, eventRepo.Events[1].Properties[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestAPICreateEventsBatch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	site := models.Site{UserID: 1, Name: "Test", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+
+	tests := []struct {
+		name        string
+		token       string
+		contentType string
+		body        string
+		wantCode    int
+		wantBody    string
+		wantCreated int
+	}{
+		{
+			name:        "JSON array",
+			token:       site.Token,
+			contentType: "application/json",
+			body:        `[{"action": "pageview", "count": 1}, {"action": "signup", "count": 2}]`,
+			wantCode:    http.StatusCreated,
+			wantBody:    `"created":2`,
+			wantCreated: 2,
+		},
+		{
+			name:        "NDJSON",
+			token:       site.Token,
+			contentType: "application/x-ndjson",
+			body:        "{\"action\": \"pageview\"}\n\n{\"action\": \"signup\"}\n",
+			wantCode:    http.StatusCreated,
+			wantBody:    `"created":2`,
+			wantCreated: 2,
+		},
+		{
+			name:        "Partial failure",
+			token:       site.Token,
+			contentType: "application/json",
+			body:        `[{"action": "pageview"}, {"action": ""}, {"action": "signup", "count": "one"}]`,
+			wantCode:    http.StatusMultiStatus,
+			wantBody:    `{"index":1,"status":"invalid","errors":{"action":"This field cannot be blank"}}`,
+			wantCreated: 1,
+		},
+		{
+			name:        "All invalid",
+			token:       site.Token,
+			contentType: "application/json",
+			body:        `[{"action": "pageview", "count": -1}]`,
+			wantCode:    http.StatusUnprocessableEntity,
+			wantBody:    `"count":"This field cannot be negative"`,
+		},
+		{
+			name:        "Unknown field",
+			token:       site.Token,
+			contentType: "application/json",
+			body:        `[{"action": "pageview"}, {"action": "signup", "prop": {"plan": "pro"}}]`,
+			wantCode:    http.StatusMultiStatus,
+			wantBody:    `{"index":1,"status":"invalid","errors":{"event":"event contains unknown field \"prop\""}}`,
+			wantCreated: 1,
+		},
+		{
+			name:        "Too large",
+			token:       site.Token,
+			contentType: "application/x-ndjson",
+			body:        strings.Repeat(`{"action": "pageview", "referrer": "`+strings.Repeat("a", 4000)+`"}`+"\n", 2600),
+			wantCode:    http.StatusRequestEntityTooLarge,
+			wantBody:    `{"error":"body must not be larger than 10485760 bytes"}`,
+		},
+		{
+			name:        "Empty batch",
+			token:       site.Token,
+			contentType: "application/json",
+			body:        `[]`,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "Malformed body",
+			token:       site.Token,
+			contentType: "application/json",
+			body:        `{"action": "pageview"}`,
+			wantCode:    http.StatusBadRequest,
+			wantBody:    "body must be a JSON array of events",
+		},
+		{
+			name:        "Unknown site token",
+			token:       "wrong-token",
+			contentType: "application/json",
+			body:        `[{"action": "pageview"}]`,
+			wantCode:    http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			eventRepo := app.Repos.Events.(*mocks.EventRepo)
+			before := len(eventRepo.Events)
+
+			code, _, body := ts.postAPI(t, "/api/events/batch", tc.token, tc.contentType, tc.body)
+			assert.Equal(t, code, tc.wantCode)
+			assert.Equal(t, len(eventRepo.Events)-before, tc.wantCreated)
+
+			if tc.wantBody != "" {
+				assert.StringContains(t, body, tc.wantBody)
+			}
+		})
+	}
+}
+
+func TestAPICreateEventVisitorID(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	site := models.Site{UserID: 1, Name: "Test", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+
+	otherSite := models.Site{UserID: 1, Name: "Other", Token: "other-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&otherSite))
+
+	eventRepo := app.Repos.Events.(*mocks.EventRepo)
+	browser := http.Header{"Origin": {"https://example.com"}}
+	for _, token := range []string{site.Token, site.Token, otherSite.Token} {
+		code, _, _ := ts.postAPIWithHeaders(t, "/api/events", token, "application/json", `{"action": "pageview"}`, browser)
+		assert.Equal(t, code, http.StatusAccepted)
+	}
+	ts.flushEvents()
+
+	first, second, other := eventRepo.Events[1].VisitorID, eventRepo.Events[2].VisitorID, eventRepo.Events[3].VisitorID
+	assert.Equal(t, first.Valid, true)
+	assert.Equal(t, len(first.String), 32)
+	assert.Equal(t, second, first)
+	assert.Equal(t, other.String != first.String, true)
+
+	t.Run("Servers and batches", func(t *testing.T) {
+		code, _, _ := ts.postAPI(t, "/api/events", site.Token, "application/json", `{"action": "signup"}`)
+		assert.Equal(t, code, http.StatusAccepted)
+		code, _, _ = ts.postAPIWithHeaders(t, "/api/events/batch", site.Token, "application/json", `[{"action": "pageview"}]`, browser)
+		assert.Equal(t, code, http.StatusCreated)
+		ts.flushEvents()
+
+		assert.Equal(t, eventRepo.Events[4].VisitorID.Valid, false)
+		assert.Equal(t, eventRepo.Events[5].VisitorID.Valid, false)
+	})
+
+	t.Run("Salt rotation", func(t *testing.T) {
+		tomorrow := time.Now().AddDate(0, 0, 1)
+		deleted, err := app.RotateVisitorSalt(tomorrow)
+		assert.Nil(t, err)
+		assert.Equal(t, deleted, int64(1))
+
+		code, _, _ := ts.postAPIWithHeaders(t, "/api/events", site.Token, "application/json", `{"action": "pageview"}`, browser)
+		assert.Equal(t, code, http.StatusAccepted)
+		ts.flushEvents()
+		assert.Equal(t, eventRepo.Events[6].VisitorID.Valid, true)
+		assert.Equal(t, eventRepo.Events[6].VisitorID.String != first.String, true)
+	})
+}
+
+func TestAPICreateEventPage(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	site := models.Site{UserID: 1, Name: "Test", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+	eventRepo := app.Repos.Events.(*mocks.EventRepo)
+
+	tests := []struct {
+		name         string
+		body         string
+		wantCode     int
+		wantHostname string
+		wantPath     string
+	}{
+		{
+			name:         "URL",
+			body:         `{"action": "pageview", "url": "https://Example.com:8080/blog/post/?utm_source=x&page=2#top"}`,
+			wantCode:     http.StatusAccepted,
+			wantHostname: "example.com",
+			wantPath:     "/blog/post?page=2",
+		},
+		{
+			name:         "Hostname and path",
+			body:         `{"action": "pageview", "hostname": "www.example.com", "path": "/pricing?token=secret"}`,
+			wantCode:     http.StatusAccepted,
+			wantHostname: "www.example.com",
+			wantPath:     "/pricing",
+		},
+		{
+			name:     "No page",
+			body:     `{"action": "signup"}`,
+			wantCode: http.StatusAccepted,
+		},
+		{
+			name:     "Invalid URL",
+			body:     `{"action": "pageview", "url": "not a url"}`,
+			wantCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eventCount := len(eventRepo.Events)
+			code, _, _ := ts.postAPI(t, "/api/events", site.Token, "application/json", tt.body)
+			assert.Equal(t, code, tt.wantCode)
+			ts.flushEvents()
+
+			if tt.wantCode != http.StatusAccepted {
+				assert.Equal(t, len(eventRepo.Events), eventCount)
+				return
+			}
+
+			event := eventRepo.Events[int64(eventCount+1)]
+			assert.Equal(t, event.Hostname, tt.wantHostname)
+			assert.Equal(t, event.Path, tt.wantPath)
+		})
+	}
+}
+
+func TestAPICreateEventUTM(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	site := models.Site{UserID: 1, Name: "Test", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+	eventRepo := app.Repos.Events.(*mocks.EventRepo)
+
+	tests := []struct {
+		name         string
+		body         string
+		wantSource   string
+		wantMedium   string
+		wantCampaign string
+		wantPath     string
+	}{
+		{
+			name:         "From URL",
+			body:         `{"action": "pageview", "url": "https://example.com/?utm_source=newsletter&utm_medium=email&utm_campaign=launch"}`,
+			wantSource:   "newsletter",
+			wantMedium:   "email",
+			wantCampaign: "launch",
+			wantPath:     "/",
+		},
+		{
+			name:         "Remembered by the script",
+			body:         `{"action": "signup", "url": "https://example.com/signup", "utm": {"source": "newsletter", "campaign": "launch"}}`,
+			wantSource:   "newsletter",
+			wantCampaign: "launch",
+			wantPath:     "/signup",
+		},
+		{
+			name:       "URL wins",
+			body:       `{"action": "pageview", "url": "https://example.com/?utm_source=twitter", "utm": {"source": "newsletter", "campaign": "launch"}}`,
+			wantSource: "twitter",
+			wantPath:   "/",
+		},
+		{
+			name:     "No campaign",
+			body:     `{"action": "pageview", "url": "https://example.com/"}`,
+			wantPath: "/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.postAPI(t, "/api/events", site.Token, "application/json", tt.body)
+			assert.Equal(t, code, http.StatusAccepted)
+			ts.flushEvents()
+
+			event := eventRepo.Events[int64(len(eventRepo.Events))]
+			assert.Equal(t, event.UTMSource, tt.wantSource)
+			assert.Equal(t, event.UTMMedium, tt.wantMedium)
+			assert.Equal(t, event.UTMCampaign, tt.wantCampaign)
+			assert.Equal(t, event.Path, tt.wantPath)
+		})
+	}
+}
+
+func TestAPICreateEventReferrer(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	site := models.Site{UserID: 1, Name: "Test", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+	eventRepo := app.Repos.Events.(*mocks.EventRepo)
+
+	tests := []struct {
+		name        string
+		body        string
+		wantHost    string
+		wantURL     string
+		wantSource  string
+		wantChannel string
+	}{
+		{
+			name:        "Search engine",
+			body:        `{"action": "pageview", "url": "https://example.com/", "referrer": "https://www.google.co.uk/"}`,
+			wantHost:    "google.co.uk",
+			wantURL:     "https://google.co.uk/",
+			wantSource:  "Google",
+			wantChannel: "Organic Search",
+		},
+		{
+			name:        "Full URL",
+			body:        `{"action": "pageview", "url": "https://example.com/", "referrer": "https://blog.example.org/posts/launch?session=abc"}`,
+			wantHost:    "blog.example.org",
+			wantURL:     "https://blog.example.org/posts/launch",
+			wantChannel: "Referral",
+		},
+		{
+			name:        "Host only",
+			body:        `{"action": "pageview", "referrer": "news.ycombinator.com"}`,
+			wantHost:    "news.ycombinator.com",
+			wantURL:     "https://news.ycombinator.com/",
+			wantSource:  "Hacker News",
+			wantChannel: "Social",
+		},
+		{
+			name:        "Same site",
+			body:        `{"action": "pageview", "url": "https://www.example.com/about", "referrer": "https://example.com/"}`,
+			wantChannel: "Direct",
+		},
+		{
+			name:        "Paid campaign",
+			body:        `{"action": "pageview", "url": "https://example.com/?utm_source=google&utm_medium=cpc", "referrer": "https://www.google.com/"}`,
+			wantHost:    "google.com",
+			wantURL:     "https://google.com/",
+			wantSource:  "Google",
+			wantChannel: "Paid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.postAPI(t, "/api/events", site.Token, "application/json", tt.body)
+			assert.Equal(t, code, http.StatusAccepted)
+			ts.flushEvents()
+
+			event := eventRepo.Events[int64(len(eventRepo.Events))]
+			assert.Equal(t, event.Referrer.String, tt.wantHost)
+			assert.Equal(t, event.ReferrerURL, tt.wantURL)
+			assert.Equal(t, event.ReferrerSource, tt.wantSource)
+			assert.Equal(t, event.Channel, tt.wantChannel)
+		})
+	}
+}
+
+func TestAPICreateEventQueueFull(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	site := models.Site{UserID: 1, Name: "Test", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+
+	// A queue without workers is never emptied.
+	app.Ingest.Close()
+	app.WG.Wait()
+	app.Ingest = internal.NewEventQueue(1)
+
+	code, _, _ := ts.postAPI(t, "/api/events", site.Token, "application/json", `{"action": "pageview"}`)
+	assert.Equal(t, code, http.StatusAccepted)
+
+	code, headers, body := ts.postAPI(t, "/api/events", site.Token, "application/json", `{"action": "pageview"}`)
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.Equal(t, headers.Get("Retry-After"), "1")
+	assert.StringContains(t, body, "please retry")
+
+	app.Ingest.Close()
+	code, _, _ = ts.postAPI(t, "/api/events", site.Token, "application/json", `{"action": "pageview"}`)
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+}
+
+func TestAPIRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	user := models.User{ID: 1, Email: "test@example.com"}
+	site := models.Site{UserID: user.ID, Name: "Test", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+
+	otherSite := models.Site{UserID: user.ID, Name: "Other", Token: "other-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&otherSite))
+
+	app.RateLimits = internal.NewRateLimits(
+		ratelimit.Limit{Requests: 2, Per: time.Minute},
+		ratelimit.Limit{Requests: 4, Per: time.Minute},
+	)
+
+	for range 2 {
+		code, _, _ := ts.postAPI(t, "/api/events", site.Token, "application/json", `{"action": "pageview"}`)
+		assert.Equal(t, code, http.StatusAccepted)
+	}
+
+	origin := http.Header{"Origin": {"https://example.com"}}
+	code, headers, body := ts.postAPIWithHeaders(t, "/api/events", site.Token, "application/json", `{"action": "pageview"}`, origin)
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.Equal(t, headers.Get("Retry-After"), "30")
+	assert.Equal(t, headers.Get("Access-Control-Allow-Origin"), "https://example.com")
+	assert.Equal(t, headers.Get("Access-Control-Expose-Headers"), "Retry-After")
+	assert.StringContains(t, body, "too many requests for this site")
+	ts.flushEvents()
+
+	code, _, _ = ts.postAPI(t, "/api/events/batch", otherSite.Token, "application/json", `[{"action": "pageview"}]`)
+	assert.Equal(t, code, http.StatusCreated)
+
+	code, headers, body = ts.postAPIWithHeaders(t, "/api/events", otherSite.Token, "application/json", `{"action": "pageview"}`, origin)
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.Equal(t, headers.Get("Access-Control-Allow-Origin"), "https://example.com")
+	assert.StringContains(t, body, "too many requests from this IP address")
+
+	code, _, _ = ts.postAPI(t, "/api/events", "no-such-token", "application/json", `{"action": "pageview"}`)
+	assert.Equal(t, code, http.StatusTooManyRequests)
+
+	assert.Nil(t, app.SaveRejections())
+
+	ts.loginUser(t, user)
+	code, _, body = ts.get(t, "/sites/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `<td>Over the site's budget</td>
+            <td>1</td>`)
+
+	code, _, body = ts.get(t, "/sites/2")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `<td>Over an IP address's budget</td>
+            <td>1</td>`)
+}
+
+func TestAPIAllowedHostnames(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	site := models.Site{UserID: 1, Name: "Test", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+	site.AllowedHostnames = []string{"example.com", "*.example.org"}
+	assert.Nil(t, app.Repos.Sites.UpdateSettings(&site))
+
+	tests := []struct {
+		name       string
+		headers    http.Header
+		body       string
+		wantCode   int
+		wantOrigin string
+	}{
+		{name: "Allowed origin", headers: http.Header{"Origin": {"https://example.com"}}, body: `{"action": "pageview", "url": "https://example.com/"}`, wantCode: http.StatusAccepted, wantOrigin: "https://example.com"},
+		{name: "Allowed subdomain", headers: http.Header{"Origin": {"https://www.example.org:8443"}}, body: `{"action": "pageview"}`, wantCode: http.StatusAccepted, wantOrigin: "https://www.example.org:8443"},
+		{name: "Allowed referer", headers: http.Header{"Referer": {"https://example.com/pricing"}}, body: `{"action": "pageview"}`, wantCode: http.StatusAccepted},
+		{name: "Server reporting its page", body: `{"action": "signup", "hostname": "example.com"}`, wantCode: http.StatusAccepted},
+		{name: "Without a hostname", body: `{"action": "signup"}`, wantCode: http.StatusForbidden},
+		{name: "Disallowed origin", headers: http.Header{"Origin": {"http://localhost:3000"}}, body: `{"action": "pageview"}`, wantCode: http.StatusForbidden},
+		{name: "Disallowed referer", headers: http.Header{"Referer": {"https://staging.example.com/"}}, body: `{"action": "pageview"}`, wantCode: http.StatusForbidden},
+		{name: "Apex of a wildcard", headers: http.Header{"Origin": {"https://example.org"}}, body: `{"action": "pageview"}`, wantCode: http.StatusForbidden},
+		{name: "Null origin", headers: http.Header{"Origin": {"null"}}, body: `{"action": "pageview"}`, wantCode: http.StatusForbidden},
+		{name: "Disallowed page", body: `{"action": "pageview", "url": "https://scraper.test/"}`, wantCode: http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			code, headers, body := ts.postAPIWithHeaders(t, "/api/events", site.Token, "application/json", tc.body, tc.headers)
+			assert.Equal(t, code, tc.wantCode)
+			assert.Equal(t, headers.Get("Access-Control-Allow-Origin"), tc.wantOrigin)
+			if tc.wantCode == http.StatusForbidden {
+				assert.StringContains(t, body, "aren't allowed for this site")
+			}
+		})
+	}
+
+	ts.flushEvents()
+	code, _, body := ts.postAPI(t, "/api/events/batch", site.Token, "application/json", `[{"action": "pageview", "url": "https://example.com/"}, {"action": "pageview", "hostname": "localhost"}, {"action": "signup"}]`)
+	assert.Equal(t, code, http.StatusMultiStatus)
+	assert.StringContains(t, body, `{"index":1,"status":"invalid","errors":{"hostname":"This hostname isn't allowed for this site"}}`)
+	assert.StringContains(t, body, `{"index":2,"status":"invalid","errors":{"hostname":"This hostname isn't allowed for this site"}}`)
+}
+
+func TestAPIPreflight(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	site := models.Site{UserID: 1, Name: "Test", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+	site.AllowedHostnames = []string{"example.com", "*.example.org"}
+	assert.Nil(t, app.Repos.Sites.UpdateSettings(&site))
+
+	tests := []struct {
+		name       string
+		origin     string
+		wantCode   int
+		wantOrigin string
+	}{
+		{name: "Allowed origin", origin: "https://example.com", wantCode: http.StatusOK, wantOrigin: "https://example.com"},
+		{name: "Allowed subdomain", origin: "https://blog.example.org", wantCode: http.StatusOK, wantOrigin: "https://blog.example.org"},
+		{name: "Unknown origin", origin: "https://evil.example.net", wantCode: http.StatusForbidden},
+		{name: "Unreadable origin", origin: "null", wantCode: http.StatusForbidden},
+		{name: "No origin", wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodOptions, ts.URL+"/api/events", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			req.Header.Set("Access-Control-Request-Method", "POST")
+
+			result, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result.Body.Close()
+
+			assert.Equal(t, result.StatusCode, tt.wantCode)
+			assert.Equal(t, result.Header.Get("Access-Control-Allow-Origin"), tt.wantOrigin)
+			assert.Equal(t, result.Header.Get("Access-Control-Allow-Credentials"), "")
+			assert.StringContains(t, result.Header.Get("Vary"), "Origin")
+		})
+	}
+}
+
+func TestAPIExcludedTraffic(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	site := models.Site{UserID: 1, Name: "Test", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+	site.ExcludedIPs = []string{"203.0.113.7", "198.51.100.0/24"}
+	assert.Nil(t, app.Repos.Sites.UpdateSettings(&site))
+
+	eventRepo := app.Repos.Events.(*mocks.EventRepo)
+
+	tests := []struct {
+		name     string
+		headers  http.Header
+		wantCode int
+	}{
+		{name: "Excluded IP", headers: http.Header{"X-Real-Ip": {"203.0.113.7"}}, wantCode: http.StatusNoContent},
+		{name: "Excluded range", headers: http.Header{"X-Forwarded-For": {"198.51.100.23, 10.0.0.1"}}, wantCode: http.StatusNoContent},
+		{name: "Opted out", headers: http.Header{"Cookie": {"eh_opt_out_1=1"}}, wantCode: http.StatusNoContent},
+		{name: "Another site's opt out", headers: http.Header{"Cookie": {"eh_opt_out_2=1"}}, wantCode: http.StatusAccepted},
+		{name: "Included IP", headers: http.Header{"X-Real-Ip": {"203.0.113.8"}}, wantCode: http.StatusAccepted},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			code, _, _ := ts.postAPIWithHeaders(t, "/api/events", site.Token, "application/json", `{"action": "pageview"}`, tc.headers)
+			assert.Equal(t, code, tc.wantCode)
+		})
+	}
+
+	code, _, _ := ts.postAPIWithHeaders(t, "/api/events/batch", site.Token, "application/json", `[{"action": "pageview"}]`, http.Header{"X-Real-Ip": {"203.0.113.7"}})
+	assert.Equal(t, code, http.StatusNoContent)
+
+	ts.flushEvents()
+	assert.Equal(t, len(eventRepo.Events), 2)
+}
+
+func TestAPIBotFiltering(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	user := models.User{ID: 1, Email: "test@example.com"}
+	site := models.Site{UserID: user.ID, Name: "Test", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+
+	app.Bots = bots.NewDetector(bots.DefaultUserAgentCheck(), bots.AcceptLanguageCheck)
+
+	tests := []struct {
+		name     string
+		headers  http.Header
+		wantCode int
+	}{
+		{name: "Crawler", headers: http.Header{"User-Agent": {"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"}}, wantCode: http.StatusForbidden},
+		{name: "Headless browser", headers: http.Header{"User-Agent": {"Mozilla/5.0 (X11; Linux x86_64) HeadlessChrome/129.0.0.0 Safari/537.36"}, "Origin": {"https://example.com"}, "Accept-Language": {"en-US"}}, wantCode: http.StatusForbidden},
+		{name: "Browser without a language", headers: http.Header{"Origin": {"https://example.com"}}, wantCode: http.StatusForbidden},
+		{name: "Browser", headers: http.Header{"Origin": {"https://example.com"}, "Accept-Language": {"en-CA,en;q=0.9"}}, wantCode: http.StatusAccepted},
+		{name: "Server", wantCode: http.StatusAccepted},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			code, headers, _ := ts.postAPIWithHeaders(t, "/api/events", site.Token, "application/json", `{"action": "pageview"}`, tc.headers)
+			assert.Equal(t, code, tc.wantCode)
+			assert.Equal(t, headers.Get("Access-Control-Allow-Origin"), tc.headers.Get("Origin"))
+		})
+	}
+
+	ts.flushEvents()
+	assert.Nil(t, app.SaveRejections())
+
+	ts.loginUser(t, user)
+	code, _, body := ts.get(t, "/sites/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `<td>Bot user agent</td>
+              <td>2</td>`)
+	assert.StringContains(t, body, `<td>No Accept-Language header</td>
+              <td>1</td>`)
+}
+
+func TestAPICreateEventValidation(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	site := models.Site{UserID: 1, Name: "Test", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+
+	tests := []struct {
+		name     string
+		token    string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{name: "Malformed JSON", body: `{"action": "pageview",}`, wantCode: http.StatusBadRequest, wantBody: `{"error":"event contains badly-formed JSON (at character 23)"}`},
+		{name: "Truncated JSON", body: `{"action": "pageview"`, wantCode: http.StatusBadRequest, wantBody: `{"error":"event contains badly-formed JSON"}`},
+		{name: "Empty body", body: ``, wantCode: http.StatusBadRequest, wantBody: `{"error":"body must not be empty"}`},
+		{name: "Not an object", body: `["pageview"]`, wantCode: http.StatusBadRequest, wantBody: `{"error":"event must be a JSON object"}`},
+		{name: "Wrong type", body: `{"action": "pageview", "count": "1"}`, wantCode: http.StatusBadRequest, wantBody: `{"error":"event contains the wrong type of value for the \"count\" field"}`},
+		{name: "Unknown field", body: `{"action": "pageview", "acton": "signup"}`, wantCode: http.StatusBadRequest, wantBody: `{"error":"event contains unknown field \"acton\""}`},
+		{name: "Multiple events", body: `{"action": "pageview"} {"action": "signup"}`, wantCode: http.StatusBadRequest, wantBody: `{"error":"body must only contain a single event"}`},
+		{name: "Too large", body: `{"action": "pageview", "referrer": "` + strings.Repeat("a", 64*1024) + `"}`, wantCode: http.StatusRequestEntityTooLarge, wantBody: `{"error":"body must not be larger than 65536 bytes"}`},
+		{name: "Long action", body: `{"action": "` + strings.Repeat("a", 101) + `"}`, wantCode: http.StatusUnprocessableEntity, wantBody: `"action":"This field cannot be more than 100 characters long"`},
+		{name: "Action with spaces", body: `{"action": "add to cart"}`, wantCode: http.StatusUnprocessableEntity, wantBody: `"action":"This field must start with a letter or number`},
+		{name: "Count too large", body: `{"action": "pageview", "count": 10001}`, wantCode: http.StatusUnprocessableEntity, wantBody: `{"error":"the event is invalid","errors":{"count":"This field cannot be more than 10000"}}`},
+		{name: "Valid action", body: `{"action": "video:play", "count": 10000}`, wantCode: http.StatusAccepted},
+		{name: "Unknown token", token: "unknown-token", body: `{"action": "pageview"}`, wantCode: http.StatusNotFound, wantBody: `{"error":"no site has this token"}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			token := tc.token
+			if token == "" {
+				token = site.Token
+			}
+
+			code, headers, body := ts.postAPI(t, "/api/events", token, "application/json", tc.body)
+			assert.Equal(t, code, tc.wantCode)
+			assert.StringContains(t, body, tc.wantBody)
+			if tc.wantBody != "" {
+				assert.Equal(t, headers.Get("Content-Type"), "application/json")
+			}
+		})
+	}
+
+	code, _, body := ts.postAPI(t, "/api/events", "", "application/json", `{"action": "pageview"}`)
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.Equal(t, body, `{"error":"an Authorization header with the site's token is required"}`)
+}
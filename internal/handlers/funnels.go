@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal"
+	"github.com/robyparr/event-horizon/internal/models"
+	"github.com/robyparr/event-horizon/internal/validator"
+	"github.com/robyparr/event-horizon/internal/views"
+)
+
+type funnelForm struct {
+	Name        string `form:"name"`
+	Steps       string `form:"steps"`
+	WindowHours int    `form:"window_hours"`
+	validator.Validator
+}
+
+func funnelsListHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, ok := readSite(app, w, r)
+		if !ok {
+			return
+		}
+
+		renderFunnels(app, w, r, http.StatusOK, site, funnelForm{WindowHours: 24})
+	})
+}
+
+func funnelsCreateHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, ok := readSite(app, w, r)
+		if !ok {
+			return
+		}
+
+		var form funnelForm
+		err := app.DecodePostForm(r, &form)
+		if err != nil {
+			app.ClientError(w, http.StatusBadRequest)
+			return
+		}
+
+		funnel := models.Funnel{SiteID: site.ID}
+		if !form.apply(&funnel) {
+			renderFunnels(app, w, r, http.StatusUnprocessableEntity, site, form)
+			return
+		}
+
+		err = app.Repos.Funnels.Insert(&funnel)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		app.SetFlash(w, "info", "Funnel created successfully.")
+		http.Redirect(w, r, fmt.Sprintf("/sites/%d/funnels/%d", site.ID, funnel.ID), http.StatusSeeOther)
+	})
+}
+
+func funnelsShowHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, funnel, ok := readFunnel(app, w, r)
+		if !ok {
+			return
+		}
+
+		user := app.MustGetCurrentUser(r)
+		query, err := readEventQuery(r, user.Location())
+		if err != nil {
+			app.ClientError(w, http.StatusBadRequest)
+			return
+		}
+
+		query.Goals, err = app.Repos.Goals.ListForSite(&site)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		counts, err := app.Repos.Events.FunnelCounts(&site, query, funnel)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		vm := views.NewViewModel(app, r, nil)
+		vm.Data["site"] = site
+		vm.Data["funnel"] = funnel
+		vm.Data["counts"] = counts
+		vm.Data["dateRange"] = query.Range
+		vm.Data["dateRangePresets"] = models.DateRangePresets
+		vm.Data["filters"] = query.Filters
+		app.Render(w, r, http.StatusOK, "funnels/show.html.tmpl", vm)
+	})
+}
+
+func funnelsEditHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, funnel, ok := readFunnel(app, w, r)
+		if !ok {
+			return
+		}
+
+		form := funnelForm{
+			Name:        funnel.Name,
+			Steps:       funnel.Steps.String(),
+			WindowHours: int(funnel.Window.Hours()),
+		}
+		renderFunnelEdit(app, w, r, http.StatusOK, site, funnel, form)
+	})
+}
+
+func funnelsUpdateHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, funnel, ok := readFunnel(app, w, r)
+		if !ok {
+			return
+		}
+
+		var form funnelForm
+		err := app.DecodePostForm(r, &form)
+		if err != nil {
+			app.ClientError(w, http.StatusBadRequest)
+			return
+		}
+
+		if !form.apply(&funnel) {
+			renderFunnelEdit(app, w, r, http.StatusUnprocessableEntity, site, funnel, form)
+			return
+		}
+
+		err = app.Repos.Funnels.Update(&funnel)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		app.SetFlash(w, "info", "Funnel updated successfully.")
+		http.Redirect(w, r, fmt.Sprintf("/sites/%d/funnels/%d", site.ID, funnel.ID), http.StatusSeeOther)
+	})
+}
+
+func funnelsDeleteHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, funnel, ok := readFunnel(app, w, r)
+		if !ok {
+			return
+		}
+
+		err := app.Repos.Funnels.Delete(&funnel)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		app.SetFlash(w, "info", fmt.Sprintf("'%s' has been deleted.", funnel.Name))
+		http.Redirect(w, r, fmt.Sprintf("/sites/%d/funnels", site.ID), http.StatusSeeOther)
+	})
+}
+
+// apply validates the form and copies it onto funnel.
+func (f *funnelForm) apply(funnel *models.Funnel) bool {
+	f.Name = strings.TrimSpace(f.Name)
+
+	f.CheckField(validator.NotBlank(f.Name), "name", "This field cannot be blank")
+	f.CheckField(validator.MaxChars(f.Name, 100), "name", "This field cannot be more than 100 characters long")
+	// Visitor IDs change at midnight UTC, so no visitor can be followed for
+	// longer than a day.
+	f.CheckField(f.WindowHours >= 1 && f.WindowHours <= 24, "window_hours", "This field must be between 1 and 24 hours")
+
+	steps, err := models.ParseFunnelSteps(f.Steps)
+	if err != nil {
+		f.AddFieldError("steps", "Each step must be an action followed by key=value properties")
+	} else {
+		f.CheckField(len(steps) >= 2 && len(steps) <= 10, "steps", "A funnel must have between 2 and 10 steps")
+	}
+
+	if !f.Valid() {
+		return false
+	}
+
+	funnel.Name = f.Name
+	funnel.Steps = steps
+	funnel.Window = time.Duration(f.WindowHours) * time.Hour
+	return true
+}
+
+func readFunnel(app *internal.App, w http.ResponseWriter, r *http.Request) (models.Site, models.Funnel, bool) {
+	site, ok := readSite(app, w, r)
+	if !ok {
+		return site, models.Funnel{}, false
+	}
+
+	id, err := readPathID(r, "funnelID")
+	if err != nil {
+		http.NotFound(w, r)
+		return site, models.Funnel{}, false
+	}
+
+	funnel, err := app.Repos.Funnels.FindForSite(&site, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+			return site, funnel, false
+		}
+
+		app.ServerError(w, r, err)
+		return site, funnel, false
+	}
+
+	return site, funnel, true
+}
+
+func renderFunnels(app *internal.App, w http.ResponseWriter, r *http.Request, status int, site models.Site, form funnelForm) {
+	funnels, err := app.Repos.Funnels.ListForSite(&site)
+	if err != nil {
+		app.ServerError(w, r, err)
+		return
+	}
+
+	vm := views.NewViewModel(app, r, form)
+	vm.Data["site"] = site
+	vm.Data["funnels"] = funnels
+	app.Render(w, r, status, "funnels/index.html.tmpl", vm)
+}
+
+func renderFunnelEdit(app *internal.App, w http.ResponseWriter, r *http.Request, status int, site models.Site, funnel models.Funnel, form funnelForm) {
+	vm := views.NewViewModel(app, r, form)
+	vm.Data["site"] = site
+	vm.Data["funnel"] = funnel
+	app.Render(w, r, status, "funnels/edit.html.tmpl", vm)
+}
@@ -0,0 +1,155 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+	"github.com/robyparr/event-horizon/internal/models"
+	"github.com/robyparr/event-horizon/internal/models/mocks"
+)
+
+func TestFunnels(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	user := models.User{ID: 1, Email: "test@example.com"}
+	ts.loginUser(t, user)
+	csrfToken := ts.getCSRFToken(t)
+
+	site := models.Site{UserID: user.ID, Name: "Test Site", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+
+	otherSite := models.Site{UserID: 2, Name: "Someone Else's Site", Token: "other-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&otherSite))
+
+	funnelRepo := app.Repos.Funnels.(*mocks.FunnelRepo)
+
+	t.Run("List", func(t *testing.T) {
+		status, _, body := ts.get(t, "/sites/1/funnels")
+		assert.Equal(t, status, http.StatusOK)
+		assert.StringContains(t, body, "No funnels yet!")
+
+		status, _, _ = ts.get(t, "/sites/2/funnels")
+		assert.Equal(t, status, http.StatusNotFound)
+	})
+
+	tests := []struct {
+		name        string
+		funnelName  string
+		steps       string
+		windowHours string
+		wantStatus  int
+		wantError   string
+	}{
+		{name: "Blank name", funnelName: "", steps: "pageview\npurchase", windowHours: "24", wantStatus: http.StatusUnprocessableEntity, wantError: "This field cannot be blank"},
+		{name: "One step", funnelName: "Checkout", steps: "pageview", windowHours: "24", wantStatus: http.StatusUnprocessableEntity, wantError: "A funnel must have between 2 and 10 steps"},
+		{name: "Invalid property", funnelName: "Checkout", steps: "pageview\ncheckout plan", windowHours: "24", wantStatus: http.StatusUnprocessableEntity, wantError: "Each step must be an action followed by key=value properties"},
+		{name: "Window too long", funnelName: "Checkout", steps: "pageview\npurchase", windowHours: "48", wantStatus: http.StatusUnprocessableEntity, wantError: "This field must be between 1 and 24 hours"},
+		{name: "Valid", funnelName: "Checkout", steps: "pageview\nadd_to_cart\ncheckout plan=pro\npurchase", windowHours: "2", wantStatus: http.StatusSeeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+			form.Add("name", tt.funnelName)
+			form.Add("steps", tt.steps)
+			form.Add("window_hours", tt.windowHours)
+
+			status, headers, body := ts.postForm(t, "/sites/1/funnels", form)
+			assert.Equal(t, status, tt.wantStatus)
+			if tt.wantError != "" {
+				assert.StringContains(t, body, tt.wantError)
+			} else {
+				assert.Equal(t, headers.Get("Location"), "/sites/1/funnels/1")
+			}
+		})
+	}
+
+	assert.Equal(t, len(funnelRepo.Funnels), 1)
+	assert.Equal(t, len(funnelRepo.Funnels[1].Steps), 4)
+	assert.Equal(t, funnelRepo.Funnels[1].Steps[2].Properties["plan"], "pro")
+	assert.Equal(t, funnelRepo.Funnels[1].Window, 2*time.Hour)
+
+	t.Run("Edit", func(t *testing.T) {
+		status, _, body := ts.get(t, "/sites/1/funnels/1/edit")
+		assert.Equal(t, status, http.StatusOK)
+		assert.StringContains(t, body, "checkout plan=pro")
+
+		form := url.Values{}
+		form.Add("csrf_token", csrfToken)
+		form.Add("name", "Purchase")
+		form.Add("steps", "pageview\npurchase")
+		form.Add("window_hours", "12")
+
+		status, _, _ = ts.postForm(t, "/sites/1/funnels/1", form)
+		assert.Equal(t, status, http.StatusSeeOther)
+		assert.Equal(t, funnelRepo.Funnels[1].Name, "Purchase")
+		assert.Equal(t, len(funnelRepo.Funnels[1].Steps), 2)
+		assert.Equal(t, funnelRepo.Funnels[1].Window, 12*time.Hour)
+
+		status, _, _ = ts.get(t, "/sites/2/funnels/1/edit")
+		assert.Equal(t, status, http.StatusNotFound)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("csrf_token", csrfToken)
+
+		status, _, _ := ts.postForm(t, "/sites/1/funnels/1/delete", form)
+		assert.Equal(t, status, http.StatusSeeOther)
+		assert.Equal(t, len(funnelRepo.Funnels), 0)
+
+		status, _, _ = ts.get(t, "/sites/1/funnels/1")
+		assert.Equal(t, status, http.StatusNotFound)
+	})
+}
+
+func TestFunnelsShow(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	user := models.User{ID: 1, Email: "test@example.com"}
+	ts.loginUser(t, user)
+
+	site := models.Site{UserID: user.ID, Name: "Test Site", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+
+	funnel := models.Funnel{
+		SiteID: site.ID,
+		Name:   "Checkout",
+		Window: time.Hour,
+		Steps: models.FunnelSteps{
+			{Action: "pageview"},
+			{Action: "add_to_cart"},
+			{Action: "purchase", Properties: map[string]string{"plan": "pro"}},
+		},
+	}
+	assert.Nil(t, app.Repos.Funnels.Insert(&funnel))
+
+	eventRepo := app.Repos.Events.(*mocks.EventRepo)
+	eventRepo.FunnelVisitors = []int{4, 3, 1}
+
+	code, _, body := ts.get(t, "/sites/1/funnels/1?range=today")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `<td><code>pageview</code></td>
+          <td>4</td>
+          <td>100.0%</td>`)
+	assert.StringContains(t, body, `<td><code>add_to_cart</code></td>
+          <td>3</td>
+          <td>75.0%</td>
+          <td>25.0%</td>`)
+	assert.StringContains(t, body, `<td><code>purchase plan=pro</code></td>
+          <td>1</td>
+          <td>25.0%</td>
+          <td>66.7%</td>`)
+	assert.Equal(t, eventRepo.LastQuery().Range.Preset, models.RangeToday)
+
+	code, _, _ = ts.get(t, "/sites/1/funnels/2")
+	assert.Equal(t, code, http.StatusNotFound)
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/robyparr/event-horizon/internal"
+	"github.com/robyparr/event-horizon/internal/models"
+	"github.com/robyparr/event-horizon/internal/tracking"
+	"github.com/robyparr/event-horizon/internal/validator"
+	"github.com/robyparr/event-horizon/internal/views"
+)
+
+type goalForm struct {
+	Name      string `form:"name"`
+	Dimension string `form:"dimension"`
+	Value     string `form:"value"`
+	validator.Validator
+}
+
+func goalsListHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, ok := readSite(app, w, r)
+		if !ok {
+			return
+		}
+
+		renderGoals(app, w, r, http.StatusOK, site, goalForm{Dimension: models.DimensionAction})
+	})
+}
+
+func goalsCreateHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, ok := readSite(app, w, r)
+		if !ok {
+			return
+		}
+
+		var form goalForm
+		err := app.DecodePostForm(r, &form)
+		if err != nil {
+			app.ClientError(w, http.StatusBadRequest)
+			return
+		}
+
+		goal := models.Goal{SiteID: site.ID}
+		if !form.apply(&goal) {
+			renderGoals(app, w, r, http.StatusUnprocessableEntity, site, form)
+			return
+		}
+
+		err = app.Repos.Goals.Insert(&goal)
+		if err != nil {
+			if errors.Is(err, models.ErrDuplicateGoal) {
+				form.AddFieldError("name", "A goal with this name already exists")
+				renderGoals(app, w, r, http.StatusUnprocessableEntity, site, form)
+				return
+			}
+
+			app.ServerError(w, r, err)
+			return
+		}
+
+		app.SetFlash(w, "info", "Goal created successfully.")
+		http.Redirect(w, r, fmt.Sprintf("/sites/%d/goals", site.ID), http.StatusSeeOther)
+	})
+}
+
+func goalsEditHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, goal, ok := readGoal(app, w, r)
+		if !ok {
+			return
+		}
+
+		form := goalForm{Name: goal.Name, Dimension: goal.Dimension, Value: goal.Value}
+		renderGoalEdit(app, w, r, http.StatusOK, site, goal, form)
+	})
+}
+
+func goalsUpdateHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, goal, ok := readGoal(app, w, r)
+		if !ok {
+			return
+		}
+
+		var form goalForm
+		err := app.DecodePostForm(r, &form)
+		if err != nil {
+			app.ClientError(w, http.StatusBadRequest)
+			return
+		}
+
+		if !form.apply(&goal) {
+			renderGoalEdit(app, w, r, http.StatusUnprocessableEntity, site, goal, form)
+			return
+		}
+
+		err = app.Repos.Goals.Update(&goal)
+		if err != nil {
+			if errors.Is(err, models.ErrDuplicateGoal) {
+				form.AddFieldError("name", "A goal with this name already exists")
+				renderGoalEdit(app, w, r, http.StatusUnprocessableEntity, site, goal, form)
+				return
+			}
+
+			app.ServerError(w, r, err)
+			return
+		}
+
+		app.SetFlash(w, "info", "Goal updated successfully.")
+		http.Redirect(w, r, fmt.Sprintf("/sites/%d/goals", site.ID), http.StatusSeeOther)
+	})
+}
+
+func goalsDeleteHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, goal, ok := readGoal(app, w, r)
+		if !ok {
+			return
+		}
+
+		err := app.Repos.Goals.Delete(&goal)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		app.SetFlash(w, "info", fmt.Sprintf("'%s' has been deleted.", goal.Name))
+		http.Redirect(w, r, fmt.Sprintf("/sites/%d/goals", site.ID), http.StatusSeeOther)
+	})
+}
+
+// apply validates the form and copies it onto goal. Paths are normalized the
+// same way as the paths events are stored with, so they match.
+func (f *goalForm) apply(goal *models.Goal) bool {
+	f.Name = strings.TrimSpace(f.Name)
+	f.Value = strings.TrimSpace(f.Value)
+
+	f.CheckField(validator.NotBlank(f.Name), "name", "This field cannot be blank")
+	f.CheckField(validator.MaxChars(f.Name, 100), "name", "This field cannot be more than 100 characters long")
+	f.CheckField(slices.Contains(models.GoalDimensions, f.Dimension), "dimension", "This field must be an action or a page")
+	f.CheckField(validator.NotBlank(f.Value), "value", "This field cannot be blank")
+	f.CheckField(validator.MaxChars(f.Value, 1024), "value", "This field cannot be more than 1024 characters long")
+
+	if f.Dimension == models.DimensionPath && f.Value != "" {
+		p, err := tracking.NormalizePath(f.Value)
+		f.CheckField(err == nil, "value", "This field must be a valid path")
+		f.Value = p
+	}
+
+	if !f.Valid() {
+		return false
+	}
+
+	goal.Name, goal.Dimension, goal.Value = f.Name, f.Dimension, f.Value
+	return true
+}
+
+// readSite loads the current user's site from the request path, responding
+// with a 404 if there isn't one.
+func readSite(app *internal.App, w http.ResponseWriter, r *http.Request) (models.Site, bool) {
+	id, err := readIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return models.Site{}, false
+	}
+
+	user := app.MustGetCurrentUser(r)
+	site, err := app.Repos.Sites.FindForUser(&user, id)
+	if err != nil {
+		http.NotFound(w, r)
+		return models.Site{}, false
+	}
+
+	return site, true
+}
+
+func readGoal(app *internal.App, w http.ResponseWriter, r *http.Request) (models.Site, models.Goal, bool) {
+	site, ok := readSite(app, w, r)
+	if !ok {
+		return site, models.Goal{}, false
+	}
+
+	id, err := readPathID(r, "goalID")
+	if err != nil {
+		http.NotFound(w, r)
+		return site, models.Goal{}, false
+	}
+
+	goal, err := app.Repos.Goals.FindForSite(&site, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+			return site, goal, false
+		}
+
+		app.ServerError(w, r, err)
+		return site, goal, false
+	}
+
+	return site, goal, true
+}
+
+func renderGoals(app *internal.App, w http.ResponseWriter, r *http.Request, status int, site models.Site, form goalForm) {
+	goals, err := app.Repos.Goals.ListForSite(&site)
+	if err != nil {
+		app.ServerError(w, r, err)
+		return
+	}
+
+	vm := views.NewViewModel(app, r, form)
+	vm.Data["site"] = site
+	vm.Data["goals"] = goals
+	vm.Data["goalDimensions"] = models.GoalDimensions
+	vm.Data["dimensionLabels"] = dimensionLabels
+	app.Render(w, r, status, "goals/index.html.tmpl", vm)
+}
+
+func renderGoalEdit(app *internal.App, w http.ResponseWriter, r *http.Request, status int, site models.Site, goal models.Goal, form goalForm) {
+	vm := views.NewViewModel(app, r, form)
+	vm.Data["site"] = site
+	vm.Data["goal"] = goal
+	vm.Data["goalDimensions"] = models.GoalDimensions
+	vm.Data["dimensionLabels"] = dimensionLabels
+	app.Render(w, r, status, "goals/edit.html.tmpl", vm)
+}
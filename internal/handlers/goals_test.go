@@ -0,0 +1,139 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+	"github.com/robyparr/event-horizon/internal/models"
+	"github.com/robyparr/event-horizon/internal/models/mocks"
+)
+
+func TestGoals(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	user := models.User{ID: 1, Email: "test@example.com"}
+	ts.loginUser(t, user)
+	csrfToken := ts.getCSRFToken(t)
+
+	site := models.Site{UserID: user.ID, Name: "Test Site", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+
+	otherSite := models.Site{UserID: 2, Name: "Someone Else's Site", Token: "other-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&otherSite))
+
+	goalRepo := app.Repos.Goals.(*mocks.GoalRepo)
+
+	t.Run("List", func(t *testing.T) {
+		status, _, body := ts.get(t, "/sites/1/goals")
+		assert.Equal(t, status, http.StatusOK)
+		assert.StringContains(t, body, "No goals yet!")
+
+		status, _, _ = ts.get(t, "/sites/2/goals")
+		assert.Equal(t, status, http.StatusNotFound)
+	})
+
+	tests := []struct {
+		name       string
+		goalName   string
+		dimension  string
+		value      string
+		wantStatus int
+		wantError  string
+	}{
+		{name: "Blank name", goalName: " ", dimension: "action", value: "signup", wantStatus: http.StatusUnprocessableEntity, wantError: "This field cannot be blank"},
+		{name: "Invalid dimension", goalName: "Signup", dimension: "browser", value: "Firefox", wantStatus: http.StatusUnprocessableEntity, wantError: "This field must be an action or a page"},
+		{name: "Action goal", goalName: "Signup", dimension: "action", value: "signup", wantStatus: http.StatusSeeOther},
+		{name: "Path goal", goalName: "Checkout", dimension: "path", value: "/checkout/done/?token=abc", wantStatus: http.StatusSeeOther},
+		{name: "Duplicate name", goalName: "Signup", dimension: "action", value: "register", wantStatus: http.StatusUnprocessableEntity, wantError: "A goal with this name already exists"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+			form.Add("name", tt.goalName)
+			form.Add("dimension", tt.dimension)
+			form.Add("value", tt.value)
+
+			status, headers, body := ts.postForm(t, "/sites/1/goals", form)
+			assert.Equal(t, status, tt.wantStatus)
+			if tt.wantError != "" {
+				assert.StringContains(t, body, tt.wantError)
+			} else {
+				assert.Equal(t, headers.Get("Location"), "/sites/1/goals")
+			}
+		})
+	}
+
+	assert.Equal(t, len(goalRepo.Goals), 2)
+	assert.Equal(t, goalRepo.Goals[2].Value, "/checkout/done")
+
+	t.Run("Edit", func(t *testing.T) {
+		status, _, body := ts.get(t, "/sites/1/goals/1/edit")
+		assert.Equal(t, status, http.StatusOK)
+		assert.StringContains(t, body, `value="Signup"`)
+
+		form := url.Values{}
+		form.Add("csrf_token", csrfToken)
+		form.Add("name", "Registration")
+		form.Add("dimension", "action")
+		form.Add("value", "register")
+
+		status, _, _ = ts.postForm(t, "/sites/1/goals/1", form)
+		assert.Equal(t, status, http.StatusSeeOther)
+		assert.Equal(t, goalRepo.Goals[1].Name, "Registration")
+		assert.Equal(t, goalRepo.Goals[1].Value, "register")
+
+		status, _, _ = ts.get(t, "/sites/2/goals/1/edit")
+		assert.Equal(t, status, http.StatusNotFound)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("csrf_token", csrfToken)
+
+		status, _, _ := ts.postForm(t, "/sites/1/goals/1/delete", form)
+		assert.Equal(t, status, http.StatusSeeOther)
+		assert.Equal(t, len(goalRepo.Goals), 1)
+
+		status, _, _ = ts.postForm(t, "/sites/1/goals/1/delete", form)
+		assert.Equal(t, status, http.StatusNotFound)
+	})
+}
+
+func TestSitesShowGoals(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	user := models.User{ID: 1, Email: "test@example.com"}
+	ts.loginUser(t, user)
+
+	site := models.Site{UserID: user.ID, Name: "Test Site", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+
+	goal := models.Goal{SiteID: site.ID, Name: "Signup", Dimension: models.DimensionAction, Value: "signup"}
+	assert.Nil(t, app.Repos.Goals.Insert(&goal))
+
+	eventRepo := app.Repos.Events.(*mocks.EventRepo)
+	eventRepo.EventTotals = models.EventTotals{Events: 5, Count: 5, Visitors: 3}
+	eventRepo.Visits = models.VisitStats{Visits: 4}
+	eventRepo.Conversions[goal.ID] = models.GoalConversion{Events: 1, Visitors: 1, Visits: 1}
+
+	code, _, body := ts.get(t, "/sites/1?range=today")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `title="Filter by this goal">Signup</a></td>
+                <td>1</td>
+                <td>33.3%</td>
+                <td>25.0%</td>`)
+
+	code, _, body = ts.get(t, "/sites/1?range=today&filter=goal:eq:Signup")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Goal is Signup")
+	assert.Equal(t, eventRepo.LastQuery().Filters[0], models.Filter{Dimension: models.DimensionGoal, Op: models.FilterEquals, Value: "Signup"})
+	assert.Equal(t, len(eventRepo.LastQuery().Goals), 1)
+}
@@ -4,12 +4,17 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/justinas/nosurf"
 	"github.com/robyparr/event-horizon/internal"
+	"github.com/robyparr/event-horizon/internal/bots"
 	"github.com/robyparr/event-horizon/internal/models"
 	"github.com/robyparr/event-horizon/internal/utils"
 )
@@ -40,33 +45,40 @@ func (m middleware) commonHeaders(next http.Handler) http.Handler {
 
 func (m middleware) commonAPIHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Vary", "Access-Control-Request-Method")
-
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Vary", "Origin, Access-Control-Request-Method")
 		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
 
 		next.ServeHTTP(w, r)
 	})
 }
 
-func (m middleware) loadSite(next http.Handler) http.Handler {
+// allowOrigin answers ingestion requests for the origin they came from, so
+// browsers can read the 429 and 403 responses of the checks that run before
+// the site is known. checkOrigin takes it back from origins the site doesn't
+// allow.
+func (m middleware) allowOrigin(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			return
+		if origin := r.Header.Get("Origin"); origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", "Retry-After")
 		}
 
-		token, found := strings.CutPrefix(authHeader, "Bearer ")
-		if !found {
-			w.WriteHeader(http.StatusBadRequest)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m middleware) loadSite(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || token == "" {
+			m.app.RenderJSON(w, r, http.StatusBadRequest, apiError{Error: "an Authorization header with the site's token is required"})
 			return
 		}
 
 		site, err := m.app.Repos.Sites.FindByToken(token)
 		if err != nil {
 			if errors.Is(err, models.ErrNoRecord) {
-				http.NotFound(w, r)
+				m.app.RenderJSON(w, r, http.StatusNotFound, apiError{Error: "no site has this token"})
 				return
 			}
 
@@ -74,11 +86,161 @@ func (m middleware) loadSite(next http.Handler) http.Handler {
 			return
 		}
 
+		m.app.Rejections.Track(site.Token)
 		r = m.app.SetCurrentSite(r, site)
 		next.ServeHTTP(w, r)
 	})
 }
 
+// checkOrigin turns away browsers on pages outside the site's allowed
+// hostnames, and stops echoing back their origin for CORS.
+// Requests without an Origin or Referer, like those sent from servers, are
+// left to the handlers to check the hostname of the page they report.
+func (m middleware) checkOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site := m.app.MustGetCurrentSite(r)
+		hostname, found := requestHostname(r)
+		if found && !site.AllowsHostname(hostname) {
+			w.Header().Del("Access-Control-Allow-Origin")
+			w.Header().Del("Access-Control-Expose-Headers")
+			m.app.RenderJSON(w, r, http.StatusForbidden, apiError{Error: errHostnameNotAllowed})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestHostname is the hostname of the page a request was sent from, taken
+// from its Origin or else its Referer header. An unreadable header is found
+// with a blank hostname so it can't slip past a site's allowed hostnames.
+func requestHostname(r *http.Request) (string, bool) {
+	for _, header := range []string{"Origin", "Referer"} {
+		value := r.Header.Get(header)
+		if value == "" {
+			continue
+		}
+
+		u, err := url.Parse(value)
+		if err != nil {
+			return "", true
+		}
+
+		return u.Hostname(), true
+	}
+
+	return "", false
+}
+
+// dropExcludedTraffic quietly drops events from the site's excluded IP
+// addresses and from browsers that have opted out of its stats. They're
+// answered as though they were accepted so nothing retries them.
+func (m middleware) dropExcludedTraffic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site := m.app.MustGetCurrentSite(r)
+		if site.ExcludesIP(m.app.ClientIP(r)) || hasOptedOut(r, site.ID) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// filterBots turns away requests that the app's bot checks flag. They're
+// counted against the site so what's filtered out can be audited.
+func (m middleware) filterBots(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site := m.app.MustGetCurrentSite(r)
+		ip, _ := netip.ParseAddr(m.app.ClientIP(r))
+		reason := m.app.Bots.Detect(bots.Request{
+			SiteID:    site.ID,
+			IP:        ip,
+			UserAgent: r.UserAgent(),
+			Header:    r.Header,
+			Time:      time.Now(),
+		})
+		if reason != "" {
+			m.app.Rejections.Add(site.Token, reason)
+			m.app.RenderJSON(w, r, http.StatusForbidden, apiError{Error: "requests from bots aren't accepted"})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitIP rejects ingestion requests once the client's IP address has
+// used up its budget. It runs before loadSite so a flood of requests doesn't
+// reach the database.
+func (m middleware) rateLimitIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, retryAfter := m.app.RateLimits.AllowIP(m.app.ClientIP(r))
+		if !ok {
+			// The site hasn't been looked up yet, so the rejection is only
+			// counted if the token has already been matched to a site.
+			token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			m.app.Rejections.Add(token, internal.RateLimitIP)
+			m.tooManyRequests(w, r, retryAfter, "too many requests from this IP address")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitToken rejects ingestion requests once the site has used up its
+// budget.
+func (m middleware) rateLimitToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site := m.app.MustGetCurrentSite(r)
+		ok, retryAfter := m.app.RateLimits.AllowToken(site.Token)
+		if !ok {
+			m.app.Rejections.Add(site.Token, internal.RateLimitToken)
+			m.tooManyRequests(w, r, retryAfter, "too many requests for this site")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m middleware) tooManyRequests(w http.ResponseWriter, r *http.Request, retryAfter time.Duration, msg string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	m.app.RenderJSON(w, r, http.StatusTooManyRequests, apiError{Error: msg})
+}
+
+func (m middleware) authenticateAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || key == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			m.app.RenderJSON(w, r, http.StatusUnauthorized, apiError{Error: "an API key is required"})
+			return
+		}
+
+		apiKey, err := m.app.Repos.APIKeys.FindByToken(internal.HashAPIKey(key))
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				m.app.RenderJSON(w, r, http.StatusUnauthorized, apiError{Error: "invalid API key"})
+				return
+			}
+
+			m.app.ServerError(w, r, fmt.Errorf("[authenticateAPIKey] %w", err))
+			return
+		}
+
+		r = m.app.SetAPIKey(r, apiKey)
+		next.ServeHTTP(w, r)
+
+		err = m.app.Repos.APIKeys.Touch(&apiKey)
+		if err != nil {
+			m.app.Logger.Error(fmt.Sprintf("[authenticateAPIKey] %s", err))
+		}
+	})
+}
+
 func (m middleware) cacheStaticAssets(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if m.app.Config.IsProductionEnv() {
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/robyparr/event-horizon/internal"
+	"github.com/robyparr/event-horizon/internal/models"
+	"github.com/robyparr/event-horizon/internal/views"
+)
+
+// optOutMaxAge keeps a browser opted out of a site's stats for two years.
+const optOutMaxAge = 2 * 365 * 24 * 60 * 60
+
+func optOutFormHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, ok := readSiteByToken(app, w, r)
+		if !ok {
+			return
+		}
+
+		vm := views.NewViewModel(app, r, nil)
+		vm.Data["site"] = site
+		app.Render(w, r, http.StatusOK, "sites/opt_out.html.tmpl", vm)
+	})
+}
+
+// optOutHandler opts the visiting browser out of a site's stats, so a site's
+// team can keep their own visits out of its numbers wherever they browse from.
+// It's only done from the opt-out page's form, so a link or image on another
+// page can't opt anyone out.
+func optOutHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, ok := readSiteByToken(app, w, r)
+		if !ok {
+			return
+		}
+
+		http.SetCookie(w, optOutCookie(site, optOutMaxAge))
+
+		app.SetFlash(w, "info", fmt.Sprintf("Your visits to %s won't be counted from this browser.", site.Name))
+		http.Redirect(w, r, fmt.Sprintf("/opt-out/%s", site.Token), http.StatusSeeOther)
+	})
+}
+
+func optInHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, ok := readSiteByToken(app, w, r)
+		if !ok {
+			return
+		}
+
+		http.SetCookie(w, optOutCookie(site, -1))
+
+		app.SetFlash(w, "info", fmt.Sprintf("Your visits to %s are being counted again.", site.Name))
+		http.Redirect(w, r, fmt.Sprintf("/opt-out/%s", site.Token), http.StatusSeeOther)
+	})
+}
+
+// optOutCookie is only sent to the events API. It's sent from the site's own
+// pages, so it has to be allowed on cross-site requests.
+func optOutCookie(site models.Site, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     optOutCookieName(site.ID),
+		Value:    "1",
+		Path:     "/api/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	}
+}
+
+func optOutCookieName(siteID int64) string {
+	return fmt.Sprintf("eh_opt_out_%d", siteID)
+}
+
+func hasOptedOut(r *http.Request, siteID int64) bool {
+	_, err := r.Cookie(optOutCookieName(siteID))
+	return err == nil
+}
+
+func readSiteByToken(app *internal.App, w http.ResponseWriter, r *http.Request) (models.Site, bool) {
+	site, err := app.Repos.Sites.FindByToken(r.PathValue("token"))
+	if err != nil {
+		http.NotFound(w, r)
+		return models.Site{}, false
+	}
+
+	return site, true
+}
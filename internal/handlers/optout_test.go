@@ -0,0 +1,48 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+	"github.com/robyparr/event-horizon/internal/models"
+)
+
+func TestOptOut(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	site := models.Site{UserID: 1, Name: "Test Site", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+
+	code, headers, body := ts.get(t, "/opt-out/site-token")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Opt out of Test Site's stats")
+	assert.Equal(t, strings.Contains(strings.Join(headers.Values("Set-Cookie"), "\n"), "eh_opt_out_1"), false)
+
+	csrfToken := ts.getCSRFToken(t)
+	form := url.Values{}
+	form.Add("csrf_token", csrfToken)
+	code, headers, _ = ts.postForm(t, "/opt-out/site-token", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/opt-out/site-token")
+	assert.StringContains(t, strings.Join(headers.Values("Set-Cookie"), "\n"), "eh_opt_out_1=1; Path=/api/; Max-Age=63072000; HttpOnly; Secure; SameSite=None")
+
+	code, _, body = ts.get(t, "/opt-out/site-token")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Your visits to Test Site won&#39;t be counted from this browser.")
+
+	code, _, _ = ts.postForm(t, "/opt-out/site-token", url.Values{})
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	code, headers, _ = ts.postForm(t, "/opt-out/site-token/delete", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/opt-out/site-token")
+	assert.StringContains(t, strings.Join(headers.Values("Set-Cookie"), "\n"), "eh_opt_out_1=1; Path=/api/; Max-Age=0")
+
+	code, _, _ = ts.get(t, "/opt-out/unknown-token")
+	assert.Equal(t, code, http.StatusNotFound)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal"
+	"github.com/robyparr/event-horizon/internal/models"
+)
+
+const (
+	// realtimeThrottle is the most often a stream is sent new stats, however
+	// quickly events arrive.
+	realtimeThrottle = time.Second
+
+	// realtimeRefresh is how often stats are sent without new events, so
+	// visitors drop out of the window and proxies see the stream is alive.
+	realtimeRefresh = 30 * time.Second
+)
+
+// sitesRealtimeHandler streams a site's realtime stats as server-sent events,
+// sending them again whenever the site records new events.
+func sitesRealtimeHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, ok := readSite(app, w, r)
+		if !ok {
+			return
+		}
+
+		// The stream outlives the server's write timeout.
+		rc := http.NewResponseController(w)
+		err := rc.SetWriteDeadline(time.Time{})
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		updates, unsubscribe := app.Realtime.Subscribe(site.ID)
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		send := func() error {
+			stats, err := app.Repos.Events.Realtime(&site, time.Now().Add(-models.RealtimeWindow))
+			if err != nil {
+				return err
+			}
+
+			data, err := json.Marshal(stats)
+			if err != nil {
+				return err
+			}
+
+			_, err = fmt.Fprintf(w, "event: stats\ndata: %s\n\n", data)
+			if err != nil {
+				return err
+			}
+
+			return rc.Flush()
+		}
+
+		err = send()
+		if err != nil {
+			app.Logger.Error("unable to send realtime stats", "site", site.ID, "error", err.Error())
+			return
+		}
+
+		ticker := time.NewTicker(realtimeThrottle)
+		defer ticker.Stop()
+
+		pending := false
+		lastSent := time.Now()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case _, ok := <-updates:
+				if !ok {
+					return
+				}
+				pending = true
+			case <-ticker.C:
+				if !pending && time.Since(lastSent) < realtimeRefresh {
+					continue
+				}
+
+				err = send()
+				if err != nil {
+					app.Logger.Error("unable to send realtime stats", "site", site.ID, "error", err.Error())
+					return
+				}
+				pending = false
+				lastSent = time.Now()
+			}
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package handlers_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+	"github.com/robyparr/event-horizon/internal/models"
+	"github.com/robyparr/event-horizon/internal/models/mocks"
+)
+
+func TestSitesRealtime(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	user := models.User{ID: 1, Email: "test@example.com"}
+	ts.loginUser(t, user)
+
+	site := models.Site{UserID: user.ID, Name: "Test Site", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+
+	otherSite := models.Site{UserID: 2, Name: "Someone Else's Site", Token: "other-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&otherSite))
+
+	eventRepo := app.Repos.Events.(*mocks.EventRepo)
+	eventRepo.RealtimeStats = models.RealtimeStats{
+		Visitors:  1,
+		Actions:   []models.RealtimeCount{{Name: "signup", Count: 1}},
+		Referrers: []models.RealtimeCount{{Name: "Hacker News", Count: 1}},
+	}
+
+	code, _, _ := ts.get(t, "/sites/2/realtime")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/sites/1/realtime", nil)
+	assert.Nil(t, err)
+
+	res, err := ts.Client().Do(req)
+	assert.Nil(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.Header.Get("Content-Type"), "text/event-stream")
+
+	stream := bufio.NewReader(res.Body)
+	stats := readRealtimeStats(t, stream)
+	assert.Equal(t, stats.Visitors, 1)
+
+	// New events for the site send the stats again.
+	code, _, _ = ts.postAPI(t, "/api/events", site.Token, "application/json", `{"action": "signup"}`)
+	assert.Equal(t, code, http.StatusAccepted)
+
+	stats = readRealtimeStats(t, stream)
+	assert.Equal(t, stats.Visitors, 1)
+	assert.Equal(t, stats.Actions[0], models.RealtimeCount{Name: "signup", Count: 1})
+	assert.Equal(t, stats.Referrers[0], models.RealtimeCount{Name: "Hacker News", Count: 1})
+}
+
+func readRealtimeStats(t *testing.T, stream *bufio.Reader) models.RealtimeStats {
+	t.Helper()
+
+	var event, data string
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			break
+		}
+
+		if value, ok := strings.CutPrefix(line, "event: "); ok {
+			event = value
+		}
+		if value, ok := strings.CutPrefix(line, "data: "); ok {
+			data = value
+		}
+	}
+
+	assert.Equal(t, event, "stats")
+
+	var stats models.RealtimeStats
+	err := json.Unmarshal([]byte(data), &stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return stats
+}
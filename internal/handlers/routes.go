@@ -23,6 +23,10 @@ func Routes(app *internal.App) http.Handler {
 	mux.Handle("GET /user/login", standardMiddleware.Then(userLoginFormHandler(app)))
 	mux.Handle("POST /user/login", standardMiddleware.Then(userLoginHandler(app)))
 
+	mux.Handle("GET /opt-out/{token}", standardMiddleware.Then(optOutFormHandler(app)))
+	mux.Handle("POST /opt-out/{token}", standardMiddleware.Then(optOutHandler(app)))
+	mux.Handle("POST /opt-out/{token}/delete", standardMiddleware.Then(optInHandler(app)))
+
 	// Authentication required
 	requireAuthMiddleware := standardMiddleware.Append(middleware.requireAuthentication)
 	mux.Handle("GET /{$}", requireAuthMiddleware.Then(sitesListHandler(app)))
@@ -30,16 +34,51 @@ func Routes(app *internal.App) http.Handler {
 	mux.Handle("POST /user/logout", requireAuthMiddleware.Then(userLogoutPostHandler(app)))
 	mux.Handle("GET /user/settings", requireAuthMiddleware.Then(userSettingsHandler(app)))
 	mux.Handle("POST /sessions/{id}/delete", requireAuthMiddleware.Then(userSessionsDeleteHandler(app)))
+	mux.Handle("POST /api-keys", requireAuthMiddleware.Then(userAPIKeysCreateHandler(app)))
+	mux.Handle("POST /api-keys/{id}/delete", requireAuthMiddleware.Then(userAPIKeysDeleteHandler(app)))
 
 	mux.Handle("GET /sites/{id}", requireAuthMiddleware.Then(sitesShowHandler(app)))
 	mux.Handle("POST /sites", requireAuthMiddleware.Then(sitesCreateHandler(app)))
 	mux.Handle("POST /sites/{id}/delete", requireAuthMiddleware.Then(sitesDeleteHandler(app)))
+	mux.Handle("GET /sites/{id}/settings", requireAuthMiddleware.Then(sitesSettingsHandler(app)))
+	mux.Handle("POST /sites/{id}/settings", requireAuthMiddleware.Then(sitesUpdateSettingsHandler(app)))
+
+	mux.Handle("GET /sites/{id}/realtime", requireAuthMiddleware.Then(sitesRealtimeHandler(app)))
+	mux.Handle("GET /sites/{id}/goals", requireAuthMiddleware.Then(goalsListHandler(app)))
+	mux.Handle("POST /sites/{id}/goals", requireAuthMiddleware.Then(goalsCreateHandler(app)))
+	mux.Handle("GET /sites/{id}/goals/{goalID}/edit", requireAuthMiddleware.Then(goalsEditHandler(app)))
+	mux.Handle("POST /sites/{id}/goals/{goalID}", requireAuthMiddleware.Then(goalsUpdateHandler(app)))
+	mux.Handle("POST /sites/{id}/goals/{goalID}/delete", requireAuthMiddleware.Then(goalsDeleteHandler(app)))
+	mux.Handle("GET /sites/{id}/funnels", requireAuthMiddleware.Then(funnelsListHandler(app)))
+	mux.Handle("POST /sites/{id}/funnels", requireAuthMiddleware.Then(funnelsCreateHandler(app)))
+	mux.Handle("GET /sites/{id}/funnels/{funnelID}", requireAuthMiddleware.Then(funnelsShowHandler(app)))
+	mux.Handle("GET /sites/{id}/funnels/{funnelID}/edit", requireAuthMiddleware.Then(funnelsEditHandler(app)))
+	mux.Handle("POST /sites/{id}/funnels/{funnelID}", requireAuthMiddleware.Then(funnelsUpdateHandler(app)))
+	mux.Handle("POST /sites/{id}/funnels/{funnelID}/delete", requireAuthMiddleware.Then(funnelsDeleteHandler(app)))
 
 	// API
 	apiMiddleware := alice.New(middleware.commonAPIHeaders)
 
 	mux.Handle("OPTIONS /api/", apiMiddleware.Then(apiPreflightHandler(app)))
-	mux.Handle("POST /api/events", apiMiddleware.Then(middleware.loadSite(apiCreateEventHandler(app))))
+	ingestMiddleware := apiMiddleware.Append(
+		middleware.allowOrigin,
+		middleware.rateLimitIP,
+		middleware.loadSite,
+		middleware.rateLimitToken,
+		middleware.checkOrigin,
+		middleware.dropExcludedTraffic,
+		middleware.filterBots,
+	)
+
+	mux.Handle("POST /api/events", ingestMiddleware.Then(apiCreateEventHandler(app)))
+	mux.Handle("POST /api/events/batch", ingestMiddleware.Then(apiCreateEventsBatchHandler(app)))
+
+	// Stats API
+	statsMiddleware := alice.New(middleware.authenticateAPIKey)
+
+	mux.Handle("GET /api/v1/stats/timeseries", statsMiddleware.Then(apiStatsTimeseriesHandler(app)))
+	mux.Handle("GET /api/v1/stats/breakdown", statsMiddleware.Then(apiStatsBreakdownHandler(app)))
+	mux.Handle("GET /api/v1/stats/aggregate", statsMiddleware.Then(apiStatsAggregateHandler(app)))
 
 	baseMiddleware := alice.New(middleware.recoverPanic, middleware.logRequest, middleware.commonHeaders)
 	return baseMiddleware.Then(mux)
@@ -4,14 +4,53 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/robyparr/event-horizon/internal"
+	"github.com/robyparr/event-horizon/internal/bots"
 	"github.com/robyparr/event-horizon/internal/models"
 	"github.com/robyparr/event-horizon/internal/validator"
 	"github.com/robyparr/event-horizon/internal/views"
 )
 
+var compareLabels = map[string]string{
+	models.ComparePrevious: "Previous period",
+	models.CompareYear:     "Same period last year",
+}
+
+var dimensionLabels = map[string]string{
+	models.DimensionAction:      "Action",
+	models.DimensionBrowser:     "Browser",
+	models.DimensionOS:          "OS",
+	models.DimensionDeviceType:  "Device",
+	models.DimensionReferrer:    "Referrer",
+	models.DimensionChannel:     "Channel",
+	models.DimensionHostname:    "Hostname",
+	models.DimensionPath:        "Page",
+	models.DimensionUTMSource:   "Source",
+	models.DimensionUTMMedium:   "Medium",
+	models.DimensionUTMCampaign: "Campaign",
+	models.DimensionCountry:     "Country",
+	models.DimensionRegion:      "Region",
+	models.DimensionCity:        "City",
+	models.DimensionGoal:        "Goal",
+}
+
+var botReasonLabels = map[string]string{
+	bots.ReasonUserAgent:      "Bot user agent",
+	bots.ReasonDataCenter:     "Data center network",
+	bots.ReasonAcceptLanguage: "No Accept-Language header",
+	bots.ReasonEventRate:      "Too many events",
+}
+
+var filterOpLabels = map[string]string{
+	models.FilterEquals:    "is",
+	models.FilterNotEquals: "is not",
+	models.FilterContains:  "contains",
+}
+
 type newSiteForm struct {
 	Name string `form:"name"`
 	validator.Validator
@@ -75,7 +114,54 @@ func sitesShowHandler(app *internal.App) http.Handler {
 			return
 		}
 
-		chartData, err := app.Repos.Events.CountsByDate(&site)
+		// The add filter form submits a filter as separate fields. Fold them into
+		// a single filter param so the resulting page can be bookmarked.
+		if params := r.URL.Query(); params.Has("filter_dimension") {
+			f, err := models.NewFilter(params.Get("filter_dimension"), params.Get("filter_op"), strings.TrimSpace(params.Get("filter_value")))
+			params.Del("filter_dimension")
+			params.Del("filter_op")
+			params.Del("filter_value")
+			if err == nil && !slices.Contains(params["filter"], f.String()) {
+				params.Add("filter", f.String())
+			}
+
+			http.Redirect(w, r, fmt.Sprintf("/sites/%d?%s", site.ID, params.Encode()), http.StatusSeeOther)
+			return
+		}
+
+		loc := user.Location()
+		query, err := readEventQuery(r, loc)
+		if err != nil {
+			app.ClientError(w, http.StatusBadRequest)
+			return
+		}
+
+		// The action param predates filters and is kept so that existing links
+		// still work. Each action is the same as an "action:eq:" filter.
+		filters := query.Filters
+		var actions []string
+		for _, action := range r.URL.Query()["action"] {
+			if action != "" && !slices.Contains(actions, action) {
+				actions = append(actions, action)
+				query = query.WithFilter(models.Filter{Dimension: models.DimensionAction, Op: models.FilterEquals, Value: action})
+			}
+		}
+
+		goals, err := app.Repos.Goals.ListForSite(&site)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+		query.Goals = goals
+
+		chartData, err := app.Repos.Events.CountsByDate(&site, query)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		todayRange, _ := models.NewDateRange(models.RangeToday, time.Now(), loc)
+		todayData, err := app.Repos.Events.CountsByDate(&site, query.WithRange(todayRange))
 		if err != nil {
 			app.ServerError(w, r, err)
 			return
@@ -87,7 +173,37 @@ func sitesShowHandler(app *internal.App) http.Handler {
 			return
 		}
 
-		metrics, err := app.Repos.Events.MetricCounts(&site)
+		totals, err := app.Repos.Events.Totals(&site, query)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		visitStats, err := app.Repos.Events.VisitStats(&site, query)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		metrics, err := app.Repos.Events.MetricCounts(&site, query)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		actionCounts, err := app.Repos.Events.ActionCounts(&site, query)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		goalConversions, err := app.Repos.Events.GoalConversions(&site, query, goals)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		rejected, err := app.Repos.Rejections.CountsForSite(&site, query.Range)
 		if err != nil {
 			app.ServerError(w, r, err)
 			return
@@ -99,11 +215,132 @@ func sitesShowHandler(app *internal.App) http.Handler {
 			return
 		}
 
+		propertyKeys, err := app.Repos.Events.PropertyKeys(&site, query)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		propertyKey := r.URL.Query().Get("property")
+		if !slices.Contains(propertyKeys, propertyKey) && len(propertyKeys) > 0 {
+			propertyKey = propertyKeys[0]
+		}
+
+		propertyData := "{}"
+		if propertyKey != "" {
+			breakdown, err := app.Repos.Events.PropertyBreakdown(&site, query, propertyKey)
+			if err != nil {
+				app.ServerError(w, r, err)
+				return
+			}
+
+			breakdownJSON, err := json.Marshal(breakdown)
+			if err != nil {
+				app.ServerError(w, r, err)
+				return
+			}
+
+			propertyData = string(breakdownJSON)
+		}
+
+		var compare map[string]any
+		compareMode := r.URL.Query().Get("compare")
+		if compareMode != "" {
+			compareRange, err := query.Range.CompareTo(compareMode)
+			if err != nil {
+				app.ClientError(w, http.StatusBadRequest)
+				return
+			}
+
+			compareChartData, err := app.Repos.Events.CountsByDate(&site, query.WithRange(compareRange))
+			if err != nil {
+				app.ServerError(w, r, err)
+				return
+			}
+
+			compareChartDataJSON, err := json.Marshal(compareChartData)
+			if err != nil {
+				app.ServerError(w, r, err)
+				return
+			}
+
+			compareTodayRange, _ := todayRange.CompareTo(compareMode)
+			compareTodayData, err := app.Repos.Events.CountsByDate(&site, query.WithRange(compareTodayRange))
+			if err != nil {
+				app.ServerError(w, r, err)
+				return
+			}
+
+			compareTotals, err := app.Repos.Events.Totals(&site, query.WithRange(compareRange))
+			if err != nil {
+				app.ServerError(w, r, err)
+				return
+			}
+
+			compareVisitStats, err := app.Repos.Events.VisitStats(&site, query.WithRange(compareRange))
+			if err != nil {
+				app.ServerError(w, r, err)
+				return
+			}
+
+			compareMetrics, err := app.Repos.Events.MetricCounts(&site, query.WithRange(compareRange))
+			if err != nil {
+				app.ServerError(w, r, err)
+				return
+			}
+
+			compareMetricsData, err := compareMetrics.ToJSON()
+			if err != nil {
+				app.ServerError(w, r, err)
+				return
+			}
+
+			compare = map[string]any{
+				"mode":          compareMode,
+				"label":         compareLabels[compareMode],
+				"range":         compareRange,
+				"eventsToday":   sumCounts(compareTodayData),
+				"eventsInRange": sumCounts(compareChartData),
+				"totals":        compareTotals,
+				"visitStats":    compareVisitStats,
+				"chartData":     string(compareChartDataJSON),
+				"metricsData":   compareMetricsData,
+			}
+		}
+
 		vm := views.NewViewModel(app, r, nil)
 		vm.Data["site"] = site
-		vm.Data["eventsToday"] = chartData[time.Now().UTC().Format("2006-01-02")]
+		vm.Data["eventsToday"] = sumCounts(todayData)
+		vm.Data["eventsInRange"] = sumCounts(chartData)
+		vm.Data["totals"] = totals
+		vm.Data["visitStats"] = visitStats
+		vm.Data["dateRange"] = query.Range
+		vm.Data["filters"] = filters
+		vm.Data["actions"] = actions
+		vm.Data["filterDimensions"] = models.FilterDimensions
+		vm.Data["filterOps"] = models.FilterOps
+		vm.Data["dimensionLabels"] = dimensionLabels
+		vm.Data["filterOpLabels"] = filterOpLabels
+		vm.Data["actionCounts"] = actionCounts
+		vm.Data["goalConversions"] = goalConversions
+		vm.Data["rateLimited"] = map[string]int{
+			"token": rejected[internal.RateLimitToken],
+			"ip":    rejected[internal.RateLimitIP],
+		}
+		vm.Data["botsFiltered"] = rejected
+		vm.Data["botReasons"] = bots.Reasons
+		vm.Data["botReasonLabels"] = botReasonLabels
+		vm.Data["query"] = r.URL.Query()
+		vm.Data["dateRangePresets"] = models.DateRangePresets
+		vm.Data["granularities"] = models.Granularities
+		vm.Data["granularity"] = r.URL.Query().Get("granularity")
 		vm.Data["chartData"] = string(chartDataJSON)
 		vm.Data["metricsData"] = metricsData
+		vm.Data["propertyKeys"] = propertyKeys
+		vm.Data["propertyKey"] = propertyKey
+		vm.Data["propertyData"] = propertyData
+		vm.Data["compare"] = compare
+		vm.Data["compareOptions"] = compareLabels
 		app.Render(w, r, http.StatusOK, "sites/show.html.tmpl", vm)
 	})
 }
@@ -133,3 +370,99 @@ func sitesDeleteHandler(app *internal.App) http.Handler {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 	})
 }
+
+func sumCounts(counts map[string]int) int {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+
+	return total
+}
+
+const (
+	maxAllowedHostnames = 20
+	maxExcludedIPs      = 50
+)
+
+type siteSettingsForm struct {
+	Hostnames   string `form:"hostnames"`
+	ExcludedIPs string `form:"excluded_ips"`
+	validator.Validator
+}
+
+func sitesSettingsHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, ok := readSite(app, w, r)
+		if !ok {
+			return
+		}
+
+		form := siteSettingsForm{
+			Hostnames:   strings.Join(site.AllowedHostnames, "\n"),
+			ExcludedIPs: strings.Join(site.ExcludedIPs, "\n"),
+		}
+		renderSiteSettings(app, w, r, http.StatusOK, site, form)
+	})
+}
+
+func sitesUpdateSettingsHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, ok := readSite(app, w, r)
+		if !ok {
+			return
+		}
+
+		var form siteSettingsForm
+		err := app.DecodePostForm(r, &form)
+		if err != nil {
+			app.ClientError(w, http.StatusBadRequest)
+			return
+		}
+
+		if !form.apply(&site) {
+			renderSiteSettings(app, w, r, http.StatusUnprocessableEntity, site, form)
+			return
+		}
+
+		err = app.Repos.Sites.UpdateSettings(&site)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		app.SetFlash(w, "info", "Settings saved successfully.")
+		http.Redirect(w, r, fmt.Sprintf("/sites/%d/settings", site.ID), http.StatusSeeOther)
+	})
+}
+
+// apply validates the form and copies it onto site.
+func (f *siteSettingsForm) apply(site *models.Site) bool {
+	hostnames, err := models.ParseAllowedHostnames(f.Hostnames)
+	if err != nil {
+		f.AddFieldError("hostnames", "Each line must be a hostname such as example.com or *.example.com")
+	} else {
+		f.CheckField(len(hostnames) <= maxAllowedHostnames, "hostnames", fmt.Sprintf("No more than %d hostnames are allowed", maxAllowedHostnames))
+	}
+
+	excludedIPs, err := models.ParseExcludedIPs(f.ExcludedIPs)
+	if err != nil {
+		f.AddFieldError("excluded_ips", "Each line must be an IP address such as 203.0.113.7 or a range such as 203.0.113.0/24")
+	} else {
+		f.CheckField(len(excludedIPs) <= maxExcludedIPs, "excluded_ips", fmt.Sprintf("No more than %d IP addresses are allowed", maxExcludedIPs))
+	}
+
+	if !f.Valid() {
+		return false
+	}
+
+	site.AllowedHostnames = hostnames
+	site.ExcludedIPs = excludedIPs
+	return true
+}
+
+func renderSiteSettings(app *internal.App, w http.ResponseWriter, r *http.Request, status int, site models.Site, form siteSettingsForm) {
+	vm := views.NewViewModel(app, r, form)
+	vm.Data["site"] = site
+	app.Render(w, r, status, "sites/settings.html.tmpl", vm)
+}
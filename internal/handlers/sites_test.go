@@ -0,0 +1,323 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+	"github.com/robyparr/event-horizon/internal/models"
+	"github.com/robyparr/event-horizon/internal/models/mocks"
+)
+
+func TestSitesShow(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	user := models.User{ID: 1, Email: "test@example.com"}
+	ts.loginUser(t, user)
+
+	site := models.Site{UserID: user.ID, Name: "Test Site", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Default range",
+			wantCode: http.StatusOK,
+			wantBody: `<option value="7d" selected>Last 7 days</option>`,
+		},
+		{
+			name:     "Preset range",
+			query:    "?range=30d",
+			wantCode: http.StatusOK,
+			wantBody: `<option value="30d" selected>Last 30 days</option>`,
+		},
+		{
+			name:     "Custom range",
+			query:    "?range=custom&from=2025-01-01&to=2025-01-31",
+			wantCode: http.StatusOK,
+			wantBody: `Events (2025-01-01 to 2025-01-31)`,
+		},
+		{
+			name:     "Custom range ending before it starts",
+			query:    "?range=custom&from=2025-02-01&to=2025-01-01",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Granularity",
+			query:    "?range=today&granularity=minute",
+			wantCode: http.StatusOK,
+			wantBody: `data-chart-label="Events per minute"`,
+		},
+		{
+			name:     "Granularity too fine for the range",
+			query:    "?range=90d&granularity=minute",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Compare to the previous period",
+			query:    "?range=custom&from=2025-01-08&to=2025-01-14&compare=previous",
+			wantCode: http.StatusOK,
+			wantBody: `title="2025-01-01 to 2025-01-07"`,
+		},
+		{
+			name:     "Unknown comparison",
+			query:    "?compare=tomorrow",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Unknown preset",
+			query:    "?range=forever",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			code, _, body := ts.get(t, "/sites/1"+tc.query)
+			assert.Equal(t, code, tc.wantCode)
+
+			if tc.wantBody != "" {
+				assert.StringContains(t, body, tc.wantBody)
+			}
+		})
+	}
+}
+
+func TestSitesShowActionFilter(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	user := models.User{ID: 1, Email: "test@example.com"}
+	ts.loginUser(t, user)
+
+	site := models.Site{UserID: user.ID, Name: "Test Site", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+
+	eventRepo := app.Repos.Events.(*mocks.EventRepo)
+	eventRepo.Actions = []models.ActionCount{{Action: "pageview", Events: 2, Total: 4}, {Action: "signup", Events: 1, Total: 2}}
+
+	t.Run("Unfiltered", func(t *testing.T) {
+		code, _, body := ts.get(t, "/sites/1?range=today")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, `href="/sites/1?action=pageview&amp;range=today" title="Filter by this action">pageview</a></td>
+                <td>2</td>
+                <td>4</td>`)
+	})
+
+	t.Run("Filtered to an action", func(t *testing.T) {
+		code, _, body := ts.get(t, "/sites/1?range=today&action=signup&action=signup")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, len(eventRepo.LastQuery().Filters), 1)
+		assert.Equal(t, eventRepo.LastQuery().Filters[0], models.Filter{Dimension: models.DimensionAction, Op: models.FilterEquals, Value: "signup"})
+		assert.StringContains(t, body, `<input type="hidden" name="action" value="signup" />`)
+		assert.StringContains(t, body, `href="/sites/1?range=today" class="badge filter"`)
+	})
+}
+
+func TestSitesShowFilters(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	user := models.User{ID: 1, Email: "test@example.com"}
+	ts.loginUser(t, user)
+
+	site := models.Site{UserID: user.ID, Name: "Test Site", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+
+	eventRepo := app.Repos.Events.(*mocks.EventRepo)
+
+	t.Run("Unfiltered", func(t *testing.T) {
+		code, _, body := ts.get(t, "/sites/1?range=today")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, `data-chart-filter="browser"`)
+		assert.StringContains(t, body, `<div class="label">Unique Visitors</div>`)
+	})
+
+	signup := models.Filter{Dimension: models.DimensionAction, Op: models.FilterEquals, Value: "signup"}
+	pageview := models.Filter{Dimension: models.DimensionAction, Op: models.FilterEquals, Value: "pageview"}
+	firefox := models.Filter{Dimension: models.DimensionBrowser, Op: models.FilterEquals, Value: "Firefox"}
+
+	tests := []struct {
+		name        string
+		query       string
+		wantFilters []models.Filter
+		wantBadge   string
+	}{
+		{
+			name:        "Action equals",
+			query:       "filter=action:eq:signup",
+			wantFilters: []models.Filter{signup},
+			wantBadge:   "Action is signup",
+		},
+		{
+			name:        "Several values of one dimension",
+			query:       "filter=action:eq:signup&filter=action:eq:pageview",
+			wantFilters: []models.Filter{signup, pageview},
+			wantBadge:   "Action is pageview",
+		},
+		{
+			name:        "Across dimensions",
+			query:       "filter=action:eq:pageview&filter=browser:eq:Firefox",
+			wantFilters: []models.Filter{pageview, firefox},
+			wantBadge:   "Browser is Firefox",
+		},
+		{
+			name:        "Action param with a filter",
+			query:       "action=pageview&filter=browser:eq:Firefox",
+			wantFilters: []models.Filter{firefox, pageview},
+			wantBadge:   "Action is pageview",
+		},
+		{
+			name:        "Not equal",
+			query:       "filter=browser:neq:Firefox",
+			wantFilters: []models.Filter{{Dimension: models.DimensionBrowser, Op: models.FilterNotEquals, Value: "Firefox"}},
+			wantBadge:   "Browser is not Firefox",
+		},
+		{
+			name:        "Contains",
+			query:       "filter=browser:contains:fire",
+			wantFilters: []models.Filter{{Dimension: models.DimensionBrowser, Op: models.FilterContains, Value: "fire"}},
+			wantBadge:   "Browser contains fire",
+		},
+		{
+			name:        "Direct referrer",
+			query:       "filter=referrer:eq:direct",
+			wantFilters: []models.Filter{{Dimension: models.DimensionReferrer, Op: models.FilterEquals, Value: "direct"}},
+			wantBadge:   "Referrer is direct",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, "/sites/1?range=today&"+tt.query)
+			assert.Equal(t, code, http.StatusOK)
+			assert.StringContains(t, body, tt.wantBadge+" &times;")
+
+			filters := eventRepo.LastQuery().Filters
+			assert.Equal(t, len(filters), len(tt.wantFilters))
+			for i, want := range tt.wantFilters {
+				assert.Equal(t, filters[i], want)
+			}
+		})
+	}
+
+	t.Run("Removing a filter", func(t *testing.T) {
+		code, _, body := ts.get(t, "/sites/1?range=today&filter=action:eq:signup")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, `<input type="hidden" name="filter" value="action:eq:signup" />`)
+		assert.StringContains(t, body, `href="/sites/1?range=today" class="badge filter"`)
+	})
+
+	t.Run("Adding a filter", func(t *testing.T) {
+		code, header, _ := ts.get(t, "/sites/1?range=today&filter_dimension=os&filter_op=contains&filter_value=+Mac+")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/sites/1?filter=os%3Acontains%3AMac&range=today")
+	})
+
+	for _, filter := range []string{"planet:eq:Mars", "browser:like:Fire", "browser:eq:", "browser"} {
+		t.Run("Invalid filter "+filter, func(t *testing.T) {
+			code, _, _ := ts.get(t, "/sites/1?filter="+filter)
+			assert.Equal(t, code, http.StatusBadRequest)
+		})
+	}
+}
+
+func TestSitesShowVisits(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	user := models.User{ID: 1, Email: "test@example.com"}
+	ts.loginUser(t, user)
+
+	site := models.Site{UserID: user.ID, Name: "Test Site", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+
+	eventRepo := app.Repos.Events.(*mocks.EventRepo)
+	eventRepo.Visits = models.VisitStats{
+		Visits:       3,
+		Bounces:      2,
+		Pageviews:    4,
+		Duration:     5 * time.Minute,
+		EntryActions: map[string]int{"pageview": 3},
+		ExitActions:  map[string]int{"pageview": 2, "signup": 1},
+	}
+	eventRepo.Actions = []models.ActionCount{{Action: "pageview", Events: 4, Total: 4}, {Action: "signup", Events: 1, Total: 1}}
+
+	code, _, body := ts.get(t, "/sites/1?range=today")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `<div class="label">Visits</div>
+    <div class="value">3</div>`)
+	assert.StringContains(t, body, `<div class="value">67%</div>`)
+	assert.StringContains(t, body, `<div class="value">1m 40s</div>`)
+	assert.StringContains(t, body, `<div class="value">1.3</div>`)
+	assert.StringContains(t, body, `<td>signup</td>
+                  <td>0</td>
+                  <td>1</td>`)
+
+	code, _, body = ts.get(t, "/sites/1?range=today&compare=previous")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `">&#43;0.0 (&#43;0%)</div>`)
+	assert.StringContains(t, body, `">&#43;0s (&#43;0%)</div>`)
+}
+
+func TestSitesSettings(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	user := models.User{ID: 1, Email: "test@example.com"}
+	ts.loginUser(t, user)
+	csrfToken := ts.getCSRFToken(t)
+
+	site := models.Site{UserID: user.ID, Name: "Test Site", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+
+	otherSite := models.Site{UserID: 2, Name: "Someone Else's Site", Token: "other-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&otherSite))
+
+	siteRepo := app.Repos.Sites.(*mocks.SiteRepo)
+
+	status, _, body := ts.get(t, "/sites/1/settings")
+	assert.Equal(t, status, http.StatusOK)
+	assert.StringContains(t, body, "Allowed hostnames")
+
+	status, _, _ = ts.get(t, "/sites/2/settings")
+	assert.Equal(t, status, http.StatusNotFound)
+
+	form := url.Values{}
+	form.Add("csrf_token", csrfToken)
+	form.Add("hostnames", "example.com\nnot a hostname")
+	status, _, body = ts.postForm(t, "/sites/1/settings", form)
+	assert.Equal(t, status, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Each line must be a hostname")
+
+	form.Set("hostnames", "example.com")
+	form.Set("excluded_ips", "203.0.113.7\nthe office")
+	status, _, body = ts.postForm(t, "/sites/1/settings", form)
+	assert.Equal(t, status, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Each line must be an IP address")
+
+	form.Set("hostnames", "Example.com\n*.example.org\n")
+	form.Set("excluded_ips", "203.0.113.7\n198.51.100.1/24")
+	status, headers, _ := ts.postForm(t, "/sites/1/settings", form)
+	assert.Equal(t, status, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/sites/1/settings")
+	assert.Equal(t, strings.Join(siteRepo.Sites[1].AllowedHostnames, ","), "example.com,*.example.org")
+	assert.Equal(t, strings.Join(siteRepo.Sites[1].ExcludedIPs, ","), "203.0.113.7,198.51.100.0/24")
+
+	status, _, _ = ts.postForm(t, "/sites/2/settings", form)
+	assert.Equal(t, status, http.StatusNotFound)
+	assert.Equal(t, len(siteRepo.Sites[2].AllowedHostnames), 0)
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"cmp"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/robyparr/event-horizon/internal"
+	"github.com/robyparr/event-horizon/internal/models"
+)
+
+type statsTimeseriesResult struct {
+	Date   string `json:"date"`
+	Events int    `json:"events"`
+}
+
+type statsBreakdownResult struct {
+	Value  string `json:"value"`
+	Events int    `json:"events"`
+}
+
+func apiStatsTimeseriesHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, query, ok := readStatsRequest(app, w, r)
+		if !ok {
+			return
+		}
+
+		counts, err := app.Repos.Events.CountsByDate(&site, query)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		// Bucket keys sort chronologically at every granularity.
+		results := make([]statsTimeseriesResult, 0, len(counts))
+		for _, date := range slices.Sorted(maps.Keys(counts)) {
+			results = append(results, statsTimeseriesResult{Date: date, Events: counts[date]})
+		}
+
+		app.RenderJSON(w, r, http.StatusOK, map[string]any{
+			"range":   statsRange(query.Range),
+			"results": results,
+		})
+	})
+}
+
+func apiStatsBreakdownHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, query, ok := readStatsRequest(app, w, r)
+		if !ok {
+			return
+		}
+
+		dimension := r.URL.Query().Get("dimension")
+		if !slices.Contains(models.FilterDimensions, dimension) {
+			app.RenderJSON(w, r, http.StatusBadRequest, apiError{
+				Error: "dimension must be one of " + strings.Join(models.FilterDimensions, ", "),
+			})
+			return
+		}
+
+		var counts map[string]int
+		if dimension == models.DimensionAction {
+			actionCounts, err := app.Repos.Events.ActionCounts(&site, query)
+			if err != nil {
+				app.ServerError(w, r, err)
+				return
+			}
+
+			counts = make(map[string]int, len(actionCounts))
+			for _, ac := range actionCounts {
+				counts[ac.Action] = ac.Events
+			}
+		} else {
+			metrics, err := app.Repos.Events.MetricCounts(&site, query)
+			if err != nil {
+				app.ServerError(w, r, err)
+				return
+			}
+
+			switch dimension {
+			case models.DimensionBrowser:
+				counts = metrics.Browser
+			case models.DimensionOS:
+				counts = metrics.OS
+			case models.DimensionDeviceType:
+				counts = metrics.DeviceType
+			case models.DimensionReferrer:
+				counts = metrics.Referrer
+			case models.DimensionChannel:
+				counts = metrics.Channel
+			case models.DimensionHostname:
+				counts = metrics.Hostname
+			case models.DimensionPath:
+				counts = metrics.Pages
+			case models.DimensionUTMSource:
+				counts = metrics.UTMSource
+			case models.DimensionUTMMedium:
+				counts = metrics.UTMMedium
+			case models.DimensionUTMCampaign:
+				counts = metrics.UTMCampaign
+			case models.DimensionCountry:
+				counts = metrics.Country
+			case models.DimensionRegion:
+				counts = metrics.Region
+			case models.DimensionCity:
+				counts = metrics.City
+			}
+		}
+
+		results := make([]statsBreakdownResult, 0, len(counts))
+		for value, events := range counts {
+			results = append(results, statsBreakdownResult{Value: value, Events: events})
+		}
+
+		slices.SortFunc(results, func(a, b statsBreakdownResult) int {
+			return cmp.Or(cmp.Compare(b.Events, a.Events), cmp.Compare(a.Value, b.Value))
+		})
+
+		app.RenderJSON(w, r, http.StatusOK, map[string]any{
+			"range":     statsRange(query.Range),
+			"dimension": dimension,
+			"results":   results,
+		})
+	})
+}
+
+func apiStatsAggregateHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, query, ok := readStatsRequest(app, w, r)
+		if !ok {
+			return
+		}
+
+		totals, err := app.Repos.Events.Totals(&site, query)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		visitStats, err := app.Repos.Events.VisitStats(&site, query)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		app.RenderJSON(w, r, http.StatusOK, map[string]any{
+			"range": statsRange(query.Range),
+			"results": map[string]any{
+				"events":             totals.Events,
+				"count":              totals.Count,
+				"visitors":           totals.Visitors,
+				"events_per_visitor": math.Round(totals.EventsPerVisitor()*100) / 100,
+				"visits":             visitStats.Visits,
+				"bounce_rate":        math.Round(visitStats.BounceRate()*100) / 100,
+				"visit_duration":     visitStats.AverageDuration().Seconds(),
+				"pages_per_visit":    math.Round(visitStats.PagesPerVisit()*100) / 100,
+			},
+		})
+	})
+}
+
+// readStatsRequest loads the site and query every stats endpoint works from,
+// writing an error response if either is invalid.
+func readStatsRequest(app *internal.App, w http.ResponseWriter, r *http.Request) (models.Site, models.EventQuery, bool) {
+	siteID, err := strconv.ParseInt(r.URL.Query().Get("site_id"), 10, 64)
+	if err != nil || siteID < 1 {
+		app.RenderJSON(w, r, http.StatusBadRequest, apiError{Error: "site_id must be a valid site ID"})
+		return models.Site{}, models.EventQuery{}, false
+	}
+
+	user := app.MustGetCurrentUser(r)
+	site, err := app.Repos.Sites.FindForUser(&user, siteID)
+	if err != nil {
+		app.RenderJSON(w, r, http.StatusNotFound, apiError{Error: "site not found"})
+		return models.Site{}, models.EventQuery{}, false
+	}
+
+	query, err := readEventQuery(r, user.Location())
+	if err != nil {
+		app.RenderJSON(w, r, http.StatusBadRequest, apiError{Error: strings.TrimPrefix(err.Error(), "models: ")})
+		return models.Site{}, models.EventQuery{}, false
+	}
+
+	query.Goals, err = app.Repos.Goals.ListForSite(&site)
+	if err != nil {
+		app.ServerError(w, r, err)
+		return models.Site{}, models.EventQuery{}, false
+	}
+
+	return site, query, true
+}
+
+func statsRange(dr models.DateRange) map[string]string {
+	return map[string]string{
+		"from":        dr.StartDate(),
+		"to":          dr.EndDate(),
+		"timezone":    dr.TimeZone(),
+		"granularity": dr.Granularity,
+	}
+}
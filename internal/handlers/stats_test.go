@@ -0,0 +1,169 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/robyparr/event-horizon/internal"
+	"github.com/robyparr/event-horizon/internal/assert"
+	"github.com/robyparr/event-horizon/internal/models"
+	"github.com/robyparr/event-horizon/internal/models/mocks"
+)
+
+func TestAPIStats(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	user := models.User{ID: 1, Email: "test@example.com", Timezone: "UTC"}
+	apiKey := models.APIKey{UserID: user.ID, User: user, Name: "Test", Token: internal.HashAPIKey("eh_valid")}
+	assert.Nil(t, app.Repos.APIKeys.Insert(&apiKey))
+
+	site := models.Site{UserID: user.ID, Name: "Test Site", Token: "site-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&site))
+
+	otherSite := models.Site{UserID: 2, Name: "Someone Else's Site", Token: "other-token"}
+	assert.Nil(t, app.Repos.Sites.Insert(&otherSite))
+
+	eventRepo := app.Repos.Events.(*mocks.EventRepo)
+	eventRepo.EventTotals = models.EventTotals{Events: 3, Count: 5, Visitors: 2}
+	eventRepo.Visits = models.VisitStats{Visits: 2, Bounces: 1, Pageviews: 2}
+	eventRepo.Actions = []models.ActionCount{{Action: "pageview", Events: 2, Total: 2}, {Action: "signup", Events: 1, Total: 3}}
+	eventRepo.Metrics = models.EventMetrics{
+		Browser:     map[string]int{"Firefox": 2, "Chrome": 1},
+		Pages:       map[string]int{"/": 1, "/pricing": 1},
+		UTMCampaign: map[string]int{"launch": 1},
+		Country:     map[string]int{"CA": 2, "GB": 1},
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		key      string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Missing API key",
+			path:     "/api/v1/stats/aggregate?site_id=1",
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"error":"an API key is required"}`,
+		},
+		{
+			name:     "Invalid API key",
+			path:     "/api/v1/stats/aggregate?site_id=1",
+			key:      "eh_invalid",
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"error":"invalid API key"}`,
+		},
+		{
+			name:     "Missing site",
+			path:     "/api/v1/stats/aggregate",
+			key:      "eh_valid",
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"site_id must be a valid site ID"}`,
+		},
+		{
+			name:     "Another user's site",
+			path:     "/api/v1/stats/aggregate?site_id=2",
+			key:      "eh_valid",
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":"site not found"}`,
+		},
+		{
+			name:     "Invalid range",
+			path:     "/api/v1/stats/aggregate?site_id=1&range=1y",
+			key:      "eh_valid",
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"invalid date range"}`,
+		},
+		{
+			name:     "Aggregate",
+			path:     "/api/v1/stats/aggregate?site_id=1&range=today",
+			key:      "eh_valid",
+			wantCode: http.StatusOK,
+			wantBody: `"results":{"bounce_rate":50,"count":5,"events":3,"events_per_visitor":1.5,"pages_per_visit":1,"visit_duration":0,"visitors":2,"visits":2}`,
+		},
+		{
+			name:     "Invalid filter",
+			path:     "/api/v1/stats/aggregate?site_id=1&range=today&filter=browser:like:Fire",
+			key:      "eh_valid",
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":`,
+		},
+		{
+			name:     "Breakdown",
+			path:     "/api/v1/stats/breakdown?site_id=1&range=today&dimension=browser",
+			key:      "eh_valid",
+			wantCode: http.StatusOK,
+			wantBody: `"dimension":"browser","range":{"from":`,
+		},
+		{
+			name:     "Breakdown results",
+			path:     "/api/v1/stats/breakdown?site_id=1&range=today&dimension=action",
+			key:      "eh_valid",
+			wantCode: http.StatusOK,
+			wantBody: `"results":[{"value":"pageview","events":2},{"value":"signup","events":1}]`,
+		},
+		{
+			name:     "Pages breakdown",
+			path:     "/api/v1/stats/breakdown?site_id=1&range=today&dimension=path",
+			key:      "eh_valid",
+			wantCode: http.StatusOK,
+			wantBody: `"results":[{"value":"/","events":1},{"value":"/pricing","events":1}]`,
+		},
+		{
+			name:     "Campaign breakdown",
+			path:     "/api/v1/stats/breakdown?site_id=1&range=today&dimension=utm_campaign",
+			key:      "eh_valid",
+			wantCode: http.StatusOK,
+			wantBody: `"results":[{"value":"launch","events":1}]`,
+		},
+		{
+			name:     "Country breakdown",
+			path:     "/api/v1/stats/breakdown?site_id=1&range=today&dimension=country",
+			key:      "eh_valid",
+			wantCode: http.StatusOK,
+			wantBody: `"results":[{"value":"CA","events":2},{"value":"GB","events":1}]`,
+		},
+		{
+			name:     "Breakdown without a dimension",
+			path:     "/api/v1/stats/breakdown?site_id=1",
+			key:      "eh_valid",
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"dimension must be one of action, browser, os, device_type, referrer, channel, hostname, path, utm_source, utm_medium, utm_campaign, country, region, city"}`,
+		},
+		{
+			name:     "Timeseries",
+			path:     "/api/v1/stats/timeseries?site_id=1&range=7d&granularity=day",
+			key:      "eh_valid",
+			wantCode: http.StatusOK,
+			wantBody: `"granularity":"day","timezone":"UTC"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.getAPI(t, tt.path, tt.key)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Content-Type"), "application/json")
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+
+	t.Run("Filters", func(t *testing.T) {
+		code, _, _ := ts.getAPI(t, "/api/v1/stats/aggregate?site_id=1&range=today&filter=browser:eq:Firefox&filter=action:neq:signup", "eh_valid")
+		assert.Equal(t, code, http.StatusOK)
+
+		filters := eventRepo.LastQuery().Filters
+		assert.Equal(t, len(filters), 2)
+		assert.Equal(t, filters[0], models.Filter{Dimension: models.DimensionBrowser, Op: models.FilterEquals, Value: "Firefox"})
+		assert.Equal(t, filters[1], models.Filter{Dimension: models.DimensionAction, Op: models.FilterNotEquals, Value: "signup"})
+	})
+
+	t.Run("Records when a key was last used", func(t *testing.T) {
+		key, err := app.Repos.APIKeys.FindByToken(internal.HashAPIKey("eh_valid"))
+		assert.Nil(t, err)
+		assert.Equal(t, key.LastUsedAt.Valid, true)
+	})
+}
@@ -13,14 +13,20 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/securecookie"
 	"github.com/robyparr/event-horizon/internal"
 	"github.com/robyparr/event-horizon/internal/assert"
+	"github.com/robyparr/event-horizon/internal/bots"
 	"github.com/robyparr/event-horizon/internal/handlers"
 	"github.com/robyparr/event-horizon/internal/models"
 	"github.com/robyparr/event-horizon/internal/models/mocks"
+	"github.com/robyparr/event-horizon/internal/ratelimit"
+	"github.com/robyparr/event-horizon/internal/realtime"
+	"github.com/robyparr/event-horizon/internal/tracking"
 	"github.com/robyparr/event-horizon/internal/views"
 
 	"github.com/go-playground/form/v4"
@@ -34,17 +40,38 @@ func newTestApplication(t *testing.T) *internal.App {
 		t.Fatal(err)
 	}
 
-	return &internal.App{
+	app := &internal.App{
 		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
 		Repos: &models.Repos{
-			Users:    mocks.NewUserRepo(),
-			Sessions: mocks.NewSessionRepo(),
-			Sites:    mocks.NewSiteRepo(),
+			Users:      mocks.NewUserRepo(),
+			Sessions:   mocks.NewSessionRepo(),
+			Sites:      mocks.NewSiteRepo(),
+			Events:     mocks.NewEventRepo(),
+			APIKeys:    mocks.NewAPIKeyRepo(),
+			Salts:      mocks.NewVisitorSaltRepo(),
+			Goals:      mocks.NewGoalRepo(),
+			Funnels:    mocks.NewFunnelRepo(),
+			Rejections: mocks.NewRejectionRepo(),
+		},
+		Views:           views,
+		FormDecoder:     form.NewDecoder(),
+		SecureCookie:    securecookie.New([]byte("super secret"), nil),
+		ReferrerSources: tracking.DefaultSources(),
+		Realtime:        realtime.NewBroker(),
+		RateLimits:      internal.NewRateLimits(ratelimit.Limit{}, ratelimit.Limit{}),
+		Rejections:      internal.NewRejections(),
+		Bots:            bots.NewDetector(bots.DefaultUserAgentCheck()),
+		Config: internal.Config{
+			IngestQueueSize:     100,
+			IngestWorkers:       1,
+			IngestBatchSize:     10,
+			IngestFlushInterval: 10 * time.Millisecond,
+			TrustedProxies:      internal.DefaultTrustedProxies,
 		},
-		Views:        views,
-		FormDecoder:  form.NewDecoder(),
-		SecureCookie: securecookie.New([]byte("super secret"), nil),
 	}
+	app.StartIngestion()
+
+	return app
 }
 
 type testServer struct {
@@ -71,6 +98,14 @@ func newTestServer(t *testing.T, app *internal.App) *testServer {
 	}
 }
 
+// flushEvents waits for every event queued by the API to be inserted, then
+// starts accepting events again.
+func (ts *testServer) flushEvents() {
+	ts.app.Ingest.Close()
+	ts.app.WG.Wait()
+	ts.app.StartIngestion()
+}
+
 func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, string) {
 	result, err := ts.Client().Get(ts.URL + urlPath)
 	if err != nil {
@@ -103,6 +138,62 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 	return result.StatusCode, result.Header, string(body)
 }
 
+func (ts *testServer) postAPI(t *testing.T, urlPath string, token string, contentType string, reqBody string) (int, http.Header, string) {
+	return ts.postAPIWithHeaders(t, urlPath, token, contentType, reqBody, nil)
+}
+
+func (ts *testServer) postAPIWithHeaders(t *testing.T, urlPath string, token string, contentType string, reqBody string, headers http.Header) (int, http.Header, string) {
+	req, err := http.NewRequest(http.MethodPost, ts.URL+urlPath, strings.NewReader(reqBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, values := range headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", contentType)
+
+	result, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer result.Body.Close()
+	body, err := io.ReadAll(result.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body = bytes.TrimSpace(body)
+	return result.StatusCode, result.Header, string(body)
+}
+
+func (ts *testServer) getAPI(t *testing.T, urlPath string, token string) (int, http.Header, string) {
+	req, err := http.NewRequest(http.MethodGet, ts.URL+urlPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	result, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer result.Body.Close()
+	body, err := io.ReadAll(result.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body = bytes.TrimSpace(body)
+	return result.StatusCode, result.Header, string(body)
+}
+
 func (ts *testServer) loginUser(t *testing.T, user models.User) {
 	token := rand.Text()
 	session := models.Session{User: user, UserID: user.ID, Token: fmt.Sprintf("%x", sha256.Sum256([]byte(token)))}
@@ -25,6 +25,11 @@ type userLoginForm struct {
 	validator.Validator `form:"-"`
 }
 
+type apiKeyForm struct {
+	Name                string `form:"name"`
+	validator.Validator `form:"-"`
+}
+
 func userSignupFormHandler(app *internal.App) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		app.Render(w, r, http.StatusOK, "user/signup.html.tmpl", views.NewViewModel(app, r, userSignupForm{}))
@@ -44,6 +49,7 @@ func userSignupHandler(app *internal.App) http.Handler {
 		form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
 		form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
 		form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+		form.CheckField(validator.TimeZone(form.Timezone), "timezone", "This field must be a valid time zone")
 
 		if !form.Valid() {
 			app.Render(w, r, http.StatusUnprocessableEntity, "user/signup.html.tmpl", views.NewViewModel(app, r, form))
@@ -138,26 +144,91 @@ func userLogoutPostHandler(app *internal.App) http.Handler {
 
 func userSettingsHandler(app *internal.App) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		currentUser := app.MustGetCurrentUser(r)
-		sessions, err := app.Repos.Sessions.ListForUser(&currentUser)
+		renderUserSettings(app, w, r, http.StatusOK, apiKeyForm{}, "")
+	})
+}
+
+func userAPIKeysCreateHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var form apiKeyForm
+		err := app.DecodePostForm(r, &form)
+		if err != nil {
+			app.ClientError(w, http.StatusBadRequest)
+			return
+		}
+
+		form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+		form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+		if !form.Valid() {
+			renderUserSettings(app, w, r, http.StatusUnprocessableEntity, form, "")
+			return
+		}
+
+		key, err := app.CreateAPIKey(app.MustGetCurrentUser(r).ID, form.Name)
 		if err != nil {
 			app.ServerError(w, r, err)
 			return
 		}
 
-		currentSession := app.MustGetSession(r)
-		for i, session := range sessions {
-			if session.Token == currentSession.Token {
-				sessions[i].Current = true
+		// Render rather than redirect so the key never has to be stored
+		// anywhere in the clear, even briefly in the flash cookie.
+		renderUserSettings(app, w, r, http.StatusCreated, apiKeyForm{}, key)
+	})
+}
+
+func userAPIKeysDeleteHandler(app *internal.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := readIDParam(r)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		currentUser := app.MustGetCurrentUser(r)
+		err = app.Repos.APIKeys.DeleteByID(currentUser, id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				http.NotFound(w, r)
+				return
 			}
+
+			app.ServerError(w, r, err)
+			return
 		}
 
-		vm := views.NewViewModel(app, r, nil)
-		vm.Data["sessions"] = sessions
-		app.Render(w, r, http.StatusOK, "user/settings.html.tmpl", vm)
+		app.SetFlash(w, "info", "API key revoked.")
+		http.Redirect(w, r, "/user/settings", http.StatusSeeOther)
 	})
 }
 
+func renderUserSettings(app *internal.App, w http.ResponseWriter, r *http.Request, status int, form apiKeyForm, newAPIKey string) {
+	currentUser := app.MustGetCurrentUser(r)
+	sessions, err := app.Repos.Sessions.ListForUser(&currentUser)
+	if err != nil {
+		app.ServerError(w, r, err)
+		return
+	}
+
+	currentSession := app.MustGetSession(r)
+	for i, session := range sessions {
+		if session.Token == currentSession.Token {
+			sessions[i].Current = true
+		}
+	}
+
+	apiKeys, err := app.Repos.APIKeys.ListForUser(&currentUser)
+	if err != nil {
+		app.ServerError(w, r, err)
+		return
+	}
+
+	vm := views.NewViewModel(app, r, form)
+	vm.Data["sessions"] = sessions
+	vm.Data["apiKeys"] = apiKeys
+	vm.Data["newAPIKey"] = newAPIKey
+	app.Render(w, r, status, "user/settings.html.tmpl", vm)
+}
+
 func userSessionsDeleteHandler(app *internal.App) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		id, err := readIDParam(r)
@@ -3,10 +3,12 @@ package handlers_test
 import (
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/robyparr/event-horizon/internal/assert"
 	"github.com/robyparr/event-horizon/internal/models"
+	"github.com/robyparr/event-horizon/internal/models/mocks"
 )
 
 func TestUserSignup(t *testing.T) {
@@ -29,6 +31,7 @@ func TestUserSignup(t *testing.T) {
 		userName     string
 		userEmail    string
 		userPassword string
+		userTimezone string
 		csrfToken    string
 		wantCode     int
 		wantFormTag  string
@@ -37,6 +40,7 @@ func TestUserSignup(t *testing.T) {
 			name:         "Valid submission",
 			userEmail:    validEmail,
 			userPassword: validPassword,
+			userTimezone: "America/Vancouver",
 			csrfToken:    validCSRFToken,
 			wantCode:     http.StatusSeeOther,
 		},
@@ -83,6 +87,25 @@ func TestUserSignup(t *testing.T) {
 			name:         "Duplicate email",
 			userEmail:    "dupe@example.com",
 			userPassword: validPassword,
+			userTimezone: "UTC",
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Server time zone",
+			userEmail:    "local@example.com",
+			userPassword: validPassword,
+			userTimezone: "Local",
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Unknown time zone",
+			userEmail:    "mars@example.com",
+			userPassword: validPassword,
+			userTimezone: "Mars/Olympus_Mons",
 			csrfToken:    validCSRFToken,
 			wantCode:     http.StatusUnprocessableEntity,
 			wantFormTag:  formTag,
@@ -94,6 +117,7 @@ func TestUserSignup(t *testing.T) {
 			form := url.Values{}
 			form.Add("email", tt.userEmail)
 			form.Add("password", tt.userPassword)
+			form.Add("timezone", tt.userTimezone)
 			form.Add("csrf_token", tt.csrfToken)
 
 			code, _, body := ts.postForm(t, "/user/signup", form)
@@ -259,3 +283,57 @@ func TestUserSessionsDelete(t *testing.T) {
 	status, _, _ = ts.postForm(t, "/sessions/1/delete", form)
 	assert.Equal(t, status, http.StatusSeeOther)
 }
+
+func TestUserAPIKeys(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+	defer ts.Close()
+
+	user := models.User{ID: 1, Email: "test@example.com"}
+	ts.loginUser(t, user)
+	csrfToken := ts.getCSRFToken(t)
+
+	t.Run("Blank name", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("csrf_token", csrfToken)
+		form.Add("name", " ")
+
+		status, _, body := ts.postForm(t, "/api-keys", form)
+		assert.Equal(t, status, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "This field cannot be blank")
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("csrf_token", csrfToken)
+		form.Add("name", "Reporting")
+
+		status, _, body := ts.postForm(t, "/api-keys", form)
+		assert.Equal(t, status, http.StatusCreated)
+		assert.StringContains(t, body, "won't be shown again")
+		assert.StringContains(t, body, `<code id="new-api-key">eh_`)
+
+		key := app.Repos.APIKeys.(*mocks.APIKeyRepo).Keys[1]
+		assert.Equal(t, key.Name, "Reporting")
+		assert.StringContains(t, body, "<code>"+key.TokenPrefix+"&hellip;</code>")
+		assert.Equal(t, strings.Contains(body, key.Token), false)
+
+		status, _, body = ts.get(t, "/user/settings")
+		assert.Equal(t, status, http.StatusOK)
+		assert.StringContains(t, body, "Reporting")
+		assert.Equal(t, strings.Contains(body, "new-api-key"), false)
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("csrf_token", csrfToken)
+
+		status, headers, _ := ts.postForm(t, "/api-keys/1/delete", form)
+		assert.Equal(t, status, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/settings")
+		assert.Equal(t, len(app.Repos.APIKeys.(*mocks.APIKeyRepo).Keys), 0)
+
+		status, _, _ = ts.postForm(t, "/api-keys/1/delete", form)
+		assert.Equal(t, status, http.StatusNotFound)
+	})
+}
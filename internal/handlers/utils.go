@@ -1,16 +1,66 @@
 package handlers
 
 import (
-	"errors"
+	"cmp"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/models"
 )
 
 func readIDParam(r *http.Request) (int64, error) {
-	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	return readPathID(r, "id")
+}
+
+func readPathID(r *http.Request, name string) (int64, error) {
+	id, err := strconv.ParseInt(r.PathValue(name), 10, 64)
 	if err != nil || id < 1 {
-		return 0, errors.New("invalid id parameter")
+		return 0, fmt.Errorf("invalid %s parameter", name)
 	}
 
 	return id, nil
 }
+
+func readDateRange(r *http.Request, loc *time.Location) (models.DateRange, error) {
+	var dr models.DateRange
+	var err error
+
+	query := r.URL.Query()
+	preset := cmp.Or(query.Get("range"), models.Range7Days)
+	if preset == models.RangeCustom {
+		dr, err = models.NewCustomDateRange(query.Get("from"), query.Get("to"), loc)
+	} else {
+		dr, err = models.NewDateRange(preset, time.Now(), loc)
+	}
+
+	if err != nil {
+		return dr, err
+	}
+
+	if granularity := query.Get("granularity"); granularity != "" {
+		return dr.WithGranularity(granularity)
+	}
+
+	return dr, nil
+}
+
+func readEventQuery(r *http.Request, loc *time.Location) (models.EventQuery, error) {
+	dr, err := readDateRange(r, loc)
+	if err != nil {
+		return models.EventQuery{}, err
+	}
+
+	q := models.EventQuery{Range: dr}
+	for _, value := range r.URL.Query()["filter"] {
+		f, err := models.ParseFilter(value)
+		if err != nil {
+			return models.EventQuery{}, err
+		}
+
+		q = q.WithFilter(f)
+	}
+
+	return q, nil
+}
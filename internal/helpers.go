@@ -2,6 +2,7 @@ package internal
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -43,6 +44,18 @@ func (app *App) Render(w http.ResponseWriter, r *http.Request, status int, page
 	buf.WriteTo(w)
 }
 
+func (app *App) RenderJSON(w http.ResponseWriter, r *http.Request, status int, data any) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		app.ServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+}
+
 func (app *App) DecodePostForm(r *http.Request, dest any) error {
 	err := r.ParseForm()
 	if err != nil {
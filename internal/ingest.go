@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/models"
+)
+
+var (
+	ErrQueueFull   = errors.New("event queue is full")
+	ErrQueueClosed = errors.New("event queue is closed")
+)
+
+// EventQueue buffers events from the API so requests don't wait on the
+// database. Workers started by StartIngestion insert them in batches.
+type EventQueue struct {
+	mu     sync.RWMutex
+	closed bool
+	events chan *models.Event
+}
+
+func NewEventQueue(size int) *EventQueue {
+	return &EventQueue{events: make(chan *models.Event, max(size, 0))}
+}
+
+// Enqueue adds e to the queue without blocking, returning ErrQueueFull if
+// there's no room for it.
+func (q *EventQueue) Enqueue(e *models.Event) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case q.events <- e:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops the queue accepting events. Workers insert whatever is left in
+// it and then exit.
+func (q *EventQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.closed {
+		q.closed = true
+		close(q.events)
+	}
+}
+
+// StartIngestion creates app.Ingest and starts its workers in the background,
+// so shutdown waits on app.WG for the queue to drain once it's closed.
+func (app *App) StartIngestion() {
+	app.Ingest = NewEventQueue(app.Config.IngestQueueSize)
+	for range max(app.Config.IngestWorkers, 1) {
+		app.InBackground("event ingestion", func() []any {
+			return app.ingestEvents(app.Ingest)
+		})
+	}
+}
+
+// ingestEvents inserts events from q until it's closed and empty, in batches
+// of up to IngestBatchSize. Smaller batches are inserted after
+// IngestFlushInterval so events don't wait long on a quiet site. A batch that
+// panics is dropped rather than taking the worker down with it.
+func (app *App) ingestEvents(q *EventQueue) []any {
+	batchSize := max(app.Config.IngestBatchSize, 1)
+	ticker := time.NewTicker(max(app.Config.IngestFlushInterval, time.Millisecond))
+	defer ticker.Stop()
+
+	inserted, dropped := 0, 0
+	batch := make([]*models.Event, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		defer func() {
+			if err := recover(); err != nil {
+				dropped += len(batch)
+				app.Logger.Error("Unable to insert events", "count", len(batch), "error", fmt.Sprintf("%v", err))
+			}
+			batch = make([]*models.Event, 0, batchSize)
+		}()
+
+		saved := app.insertEvents(batch)
+		inserted += len(saved)
+		dropped += len(batch) - len(saved)
+		app.publishInserted(saved)
+	}
+
+	for {
+		select {
+		case e, ok := <-q.events:
+			if !ok {
+				flush()
+				return []any{"inserted", inserted, "dropped", dropped}
+			}
+
+			batch = append(batch, e)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// insertEvents inserts a batch of events in one go, falling back to one at a
+// time when that fails so a single bad event, perhaps from another site,
+// doesn't lose the rest. It returns the events that were inserted.
+func (app *App) insertEvents(batch []*models.Event) []*models.Event {
+	err := app.Repos.Events.InsertMany(batch)
+	if err == nil {
+		return batch
+	}
+	app.Logger.Warn("Unable to insert events as a batch, inserting them one at a time", "count", len(batch), "error", err.Error())
+
+	saved := make([]*models.Event, 0, len(batch))
+	for _, e := range batch {
+		err := app.Repos.Events.Insert(e)
+		if err != nil {
+			app.Logger.Error("Unable to insert event", "site_id", e.SiteID, "action", e.Action, "error", err.Error())
+			continue
+		}
+
+		saved = append(saved, e)
+	}
+
+	return saved
+}
+
+// publishInserted tells realtime subscribers which sites have new events.
+func (app *App) publishInserted(events []*models.Event) {
+	published := make(map[int64]bool)
+	for _, e := range events {
+		if !published[e.SiteID] {
+			published[e.SiteID] = true
+			app.Realtime.Publish(e.SiteID)
+		}
+	}
+}
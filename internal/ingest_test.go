@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+	"github.com/robyparr/event-horizon/internal/models"
+	"github.com/robyparr/event-horizon/internal/models/mocks"
+	"github.com/robyparr/event-horizon/internal/realtime"
+)
+
+func TestIngestEventsWithABadEvent(t *testing.T) {
+	eventRepo := mocks.NewEventRepo()
+	app := &App{
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Repos:    &models.Repos{Events: eventRepo},
+		Realtime: realtime.NewBroker(),
+		Config: Config{
+			IngestQueueSize:     10,
+			IngestWorkers:       1,
+			IngestBatchSize:     10,
+			IngestFlushInterval: time.Hour,
+		},
+	}
+	app.StartIngestion()
+
+	for _, siteID := range []int64{1, 0, 2} {
+		assert.Nil(t, app.Ingest.Enqueue(&models.Event{SiteID: siteID, Action: "pageview"}))
+	}
+	app.Ingest.Close()
+	app.WG.Wait()
+
+	assert.Equal(t, len(eventRepo.Events), 2)
+	assert.Equal(t, eventRepo.Events[1].SiteID, int64(1))
+	assert.Equal(t, eventRepo.Events[2].SiteID, int64(2))
+}
+
+func TestIngestEventsAfterAPanic(t *testing.T) {
+	eventRepo := mocks.NewEventRepo()
+	app := &App{
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Repos:    &models.Repos{Events: eventRepo},
+		Realtime: realtime.NewBroker(),
+		Config: Config{
+			IngestQueueSize:     10,
+			IngestWorkers:       1,
+			IngestBatchSize:     1,
+			IngestFlushInterval: time.Hour,
+		},
+	}
+	app.StartIngestion()
+
+	// The mock repo panics on a nil event.
+	assert.Nil(t, app.Ingest.Enqueue(nil))
+	assert.Nil(t, app.Ingest.Enqueue(&models.Event{SiteID: 1, Action: "pageview"}))
+	app.Ingest.Close()
+	app.WG.Wait()
+
+	assert.Equal(t, len(eventRepo.Events), 1)
+	assert.Equal(t, eventRepo.Events[1].SiteID, int64(1))
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// APIKey grants read access to the stats API on behalf of a user. Like
+// session tokens, only a hash of the key is stored. TokenPrefix is kept in
+// the clear so keys can be told apart on the settings page.
+type APIKey struct {
+	ID          int64
+	UserID      int64
+	Name        string
+	Token       string
+	TokenPrefix string
+	LastUsedAt  sql.NullTime
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+
+	User User
+}
+
+type APIKeyRepoInterface interface {
+	Insert(k *APIKey) error
+	Touch(k *APIKey) error
+	DeleteByID(user User, id int64) error
+	FindByToken(token string) (APIKey, error)
+	ListForUser(u *User) ([]APIKey, error)
+}
+
+type APIKeyRepo struct {
+	db *sql.DB
+}
+
+func (r *APIKeyRepo) Insert(k *APIKey) error {
+	stmt := `INSERT INTO api_keys (user_id, name, token, token_prefix)
+	VALUES($1, $2, $3, $4)
+	RETURNING id, created_at, updated_at;`
+
+	args := []any{k.UserID, k.Name, k.Token, k.TokenPrefix}
+	err := r.db.QueryRow(stmt, args...).Scan(&k.ID, &k.CreatedAt, &k.UpdatedAt)
+	if err != nil {
+		if err.Error() == `pq: duplicate key value violates unique constraint "idx_api_keys_token_unique"` {
+			return ErrDuplicateToken
+		}
+
+		return fmt.Errorf("[APIKeyRepo.Insert] %w", err)
+	}
+
+	return nil
+}
+
+func (r *APIKeyRepo) Touch(k *APIKey) error {
+	stmt := `UPDATE api_keys SET last_used_at = (NOW() AT TIME ZONE 'UTC') WHERE id = $1;`
+	_, err := r.db.Exec(stmt, k.ID)
+	if err != nil {
+		return fmt.Errorf("[APIKeyRepo.Touch] %w", err)
+	}
+
+	return nil
+}
+
+func (r *APIKeyRepo) DeleteByID(user User, id int64) error {
+	result, err := r.db.Exec("DELETE FROM api_keys WHERE user_id = $1 AND id = $2;", user.ID, id)
+	if err != nil {
+		return fmt.Errorf("[APIKeyRepo.DeleteByID] %w", err)
+	}
+
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("[APIKeyRepo.DeleteByID] %w", err)
+	}
+
+	if affectedRows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (r *APIKeyRepo) FindByToken(token string) (APIKey, error) {
+	stmt := `SELECT
+		api_keys.id, api_keys.user_id, api_keys.name, api_keys.token, api_keys.token_prefix,
+		api_keys.last_used_at, api_keys.created_at, api_keys.updated_at,
+		users.id, users.email, users.hashed_password, users.timezone, users.created_at, users.updated_at
+	FROM api_keys
+	INNER JOIN users ON api_keys.user_id = users.id
+	WHERE api_keys.token = $1;`
+
+	var k APIKey
+	err := r.db.QueryRow(stmt, token).Scan(
+		&k.ID,
+		&k.UserID,
+		&k.Name,
+		&k.Token,
+		&k.TokenPrefix,
+		&k.LastUsedAt,
+		&k.CreatedAt,
+		&k.UpdatedAt,
+		&k.User.ID,
+		&k.User.Email,
+		&k.User.HashedPassword,
+		&k.User.Timezone,
+		&k.User.CreatedAt,
+		&k.User.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return k, ErrNoRecord
+		}
+
+		return k, fmt.Errorf("[APIKeyRepo.FindByToken] %w", err)
+	}
+
+	return k, nil
+}
+
+func (r *APIKeyRepo) ListForUser(u *User) ([]APIKey, error) {
+	stmt := `
+		SELECT id, user_id, name, token_prefix, last_used_at, created_at, updated_at
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at DESC;`
+
+	var keys []APIKey
+	rows, err := r.db.Query(stmt, u.ID)
+	if err != nil {
+		return keys, fmt.Errorf("[APIKeyRepo.ListForUser] %w", err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var k APIKey
+		err = rows.Scan(&k.ID, &k.UserID, &k.Name, &k.TokenPrefix, &k.LastUsedAt, &k.CreatedAt, &k.UpdatedAt)
+		if err != nil {
+			return keys, fmt.Errorf("[APIKeyRepo.ListForUser] %w", err)
+		}
+
+		keys = append(keys, k)
+	}
+
+	if err = rows.Err(); err != nil {
+		return keys, fmt.Errorf("[APIKeyRepo.ListForUser] %w", err)
+	}
+
+	return keys, nil
+}
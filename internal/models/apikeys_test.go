@@ -0,0 +1,77 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+)
+
+func TestAPIKeyRepo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	r := APIKeyRepo{db: db}
+	userRepo := UserRepo{db: db}
+
+	user := &User{Email: fmt.Sprintf("%d%s", rand.Uint64(), "@example.com"), Password: "pa$$word", Timezone: "UTC"}
+	assert.Nil(t, userRepo.Insert(user))
+	otherUser := &User{Email: fmt.Sprintf("%d%s", rand.Uint64(), "@example.com"), Password: "pa$$word", Timezone: "UTC"}
+	assert.Nil(t, userRepo.Insert(otherUser))
+
+	key := &APIKey{UserID: user.ID, Name: "Reporting", Token: "hashed-token", TokenPrefix: "eh_abcd"}
+	assert.Nil(t, r.Insert(key))
+	assert.NotEqual(t, key.ID, 0)
+
+	t.Run("Duplicate token", func(t *testing.T) {
+		err := r.Insert(&APIKey{UserID: otherUser.ID, Name: "Copy", Token: key.Token, TokenPrefix: "eh_abcd"})
+		assert.Equal(t, errors.Is(err, ErrDuplicateToken), true)
+	})
+
+	t.Run("Find by token", func(t *testing.T) {
+		found, err := r.FindByToken(key.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, found.ID, key.ID)
+		assert.Equal(t, found.Name, "Reporting")
+		assert.Equal(t, found.LastUsedAt.Valid, false)
+		assert.Equal(t, found.User.ID, user.ID)
+		assert.Equal(t, found.User.Email, user.Email)
+
+		_, err = r.FindByToken("no-such-token")
+		assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+	})
+
+	t.Run("Touch", func(t *testing.T) {
+		assert.Nil(t, r.Touch(key))
+
+		found, err := r.FindByToken(key.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, found.LastUsedAt.Valid, true)
+	})
+
+	t.Run("List for user", func(t *testing.T) {
+		keys, err := r.ListForUser(user)
+		assert.Nil(t, err)
+		assert.Equal(t, len(keys), 1)
+		assert.Equal(t, keys[0].ID, key.ID)
+		assert.Equal(t, keys[0].TokenPrefix, "eh_abcd")
+		assert.Equal(t, keys[0].Token, "")
+
+		keys, err = r.ListForUser(otherUser)
+		assert.Nil(t, err)
+		assert.Equal(t, len(keys), 0)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		err := r.DeleteByID(*otherUser, key.ID)
+		assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+		assert.Nil(t, r.DeleteByID(*user, key.ID))
+		_, err = r.FindByToken(key.Token)
+		assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+	})
+}
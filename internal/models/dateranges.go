@@ -0,0 +1,263 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	RangeToday       = "today"
+	Range7Days       = "7d"
+	Range30Days      = "30d"
+	Range90Days      = "90d"
+	RangeMonthToDate = "mtd"
+	RangeYearToDate  = "ytd"
+	RangeCustom      = "custom"
+)
+
+const (
+	GranularityMinute = "minute"
+	GranularityHour   = "hour"
+	GranularityDay    = "day"
+	GranularityWeek   = "week"
+	GranularityMonth  = "month"
+)
+
+const (
+	ComparePrevious = "previous"
+	CompareYear     = "year"
+)
+
+const dateFormat = "2006-01-02"
+
+// maxDateRangeDays caps custom ranges so a typo in a URL can't ask the
+// database for decades of daily buckets.
+const maxDateRangeDays = 5 * 366
+
+// maxTimeSeriesBuckets limits how fine the granularity can be for a given
+// range, e.g. minutes are only available for about a day.
+const maxTimeSeriesBuckets = 2000
+
+var Granularities = []string{GranularityMinute, GranularityHour, GranularityDay, GranularityWeek, GranularityMonth}
+
+var bucketKeyFormats = map[string]string{
+	GranularityMinute: "2006-01-02 15:04",
+	GranularityHour:   "2006-01-02 15:00",
+	GranularityDay:    dateFormat,
+	GranularityWeek:   dateFormat,
+	GranularityMonth:  "2006-01",
+}
+
+type DateRangePreset struct {
+	Value string
+	Label string
+}
+
+var DateRangePresets = []DateRangePreset{
+	{Value: RangeToday, Label: "Today"},
+	{Value: Range7Days, Label: "Last 7 days"},
+	{Value: Range30Days, Label: "Last 30 days"},
+	{Value: Range90Days, Label: "Last 90 days"},
+	{Value: RangeMonthToDate, Label: "Month to date"},
+	{Value: RangeYearToDate, Label: "Year to date"},
+}
+
+// DateRange is an inclusive range of whole days in Location. Start and End
+// are both midnight on their respective days. Time series over the range are
+// bucketed by Granularity.
+type DateRange struct {
+	Preset      string
+	Start       time.Time
+	End         time.Time
+	Location    *time.Location
+	Granularity string
+}
+
+func NewDateRange(preset string, now time.Time, loc *time.Location) (DateRange, error) {
+	today := startOfDay(now, loc)
+	dr := DateRange{Preset: preset, End: today, Location: loc}
+
+	switch preset {
+	case RangeToday:
+		dr.Start = today
+	case Range7Days:
+		dr.Start = today.AddDate(0, 0, -6)
+	case Range30Days:
+		dr.Start = today.AddDate(0, 0, -29)
+	case Range90Days:
+		dr.Start = today.AddDate(0, 0, -89)
+	case RangeMonthToDate:
+		dr.Start = time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, loc)
+	case RangeYearToDate:
+		dr.Start = time.Date(today.Year(), time.January, 1, 0, 0, 0, 0, loc)
+	default:
+		return DateRange{}, ErrInvalidDateRange
+	}
+
+	dr.Granularity = dr.defaultGranularity()
+	return dr, nil
+}
+
+func NewCustomDateRange(from string, to string, loc *time.Location) (DateRange, error) {
+	start, err := time.ParseInLocation(dateFormat, from, loc)
+	if err != nil {
+		return DateRange{}, ErrInvalidDateRange
+	}
+
+	end, err := time.ParseInLocation(dateFormat, to, loc)
+	if err != nil {
+		return DateRange{}, ErrInvalidDateRange
+	}
+
+	dr := DateRange{Preset: RangeCustom, Start: start, End: end, Location: loc}
+	if end.Before(start) || dr.Days() > maxDateRangeDays {
+		return DateRange{}, ErrInvalidDateRange
+	}
+
+	dr.Granularity = dr.defaultGranularity()
+	return dr, nil
+}
+
+// WithGranularity returns a copy of the range bucketed by granularity, as long
+// as that wouldn't produce an unreasonable number of buckets.
+func (dr DateRange) WithGranularity(granularity string) (DateRange, error) {
+	if _, ok := bucketKeyFormats[granularity]; !ok {
+		return dr, ErrInvalidGranularity
+	}
+
+	dr.Granularity = granularity
+	if dr.bucketCount() > maxTimeSeriesBuckets {
+		return dr, ErrInvalidGranularity
+	}
+
+	return dr, nil
+}
+
+// CompareTo returns the range that dr should be compared against: either the
+// period of the same length immediately before it, or the same dates a year
+// earlier.
+func (dr DateRange) CompareTo(mode string) (DateRange, error) {
+	compare := dr
+	compare.Preset = RangeCustom
+
+	switch mode {
+	case ComparePrevious:
+		compare.Start = dr.Start.AddDate(0, 0, -dr.Days())
+		compare.End = dr.Start.AddDate(0, 0, -1)
+	case CompareYear:
+		compare.Start = dr.Start.AddDate(-1, 0, 0)
+		compare.End = dr.End.AddDate(-1, 0, 0)
+	default:
+		return DateRange{}, ErrInvalidComparison
+	}
+
+	return compare, nil
+}
+
+// Buckets returns the start of every time series bucket in the range, in
+// Location.
+func (dr DateRange) Buckets() []time.Time {
+	var buckets []time.Time
+	end := dr.EndsBefore()
+	for t := dr.truncate(dr.Start); t.Before(end); t = dr.nextBucket(t) {
+		buckets = append(buckets, t)
+	}
+
+	return buckets
+}
+
+// BucketKey formats the wall clock time of a bucket as it appears in time
+// series results.
+func (dr DateRange) BucketKey(t time.Time) string {
+	return t.Format(bucketKeyFormats[dr.Granularity])
+}
+
+func (dr DateRange) defaultGranularity() string {
+	switch days := dr.Days(); {
+	case days == 1:
+		return GranularityHour
+	case days <= 92:
+		return GranularityDay
+	case days <= 366:
+		return GranularityWeek
+	default:
+		return GranularityMonth
+	}
+}
+
+func (dr DateRange) bucketCount() int {
+	switch dr.Granularity {
+	case GranularityMinute:
+		return int(dr.EndsBefore().Sub(dr.StartsAt()).Minutes())
+	case GranularityHour:
+		return int(dr.EndsBefore().Sub(dr.StartsAt()).Hours())
+	default:
+		return len(dr.Buckets())
+	}
+}
+
+func (dr DateRange) truncate(t time.Time) time.Time {
+	switch dr.Granularity {
+	case GranularityWeek:
+		daysSinceMonday := (int(t.Weekday()) + 6) % 7
+		return t.AddDate(0, 0, -daysSinceMonday)
+	case GranularityMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	default:
+		return t
+	}
+}
+
+func (dr DateRange) nextBucket(t time.Time) time.Time {
+	switch dr.Granularity {
+	case GranularityMinute:
+		return t.Add(time.Minute)
+	case GranularityHour:
+		return t.Add(time.Hour)
+	case GranularityWeek:
+		return t.AddDate(0, 0, 7)
+	case GranularityMonth:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// Days counts calendar days rather than dividing the duration by 24 hours,
+// since days around DST transitions are 23 or 25 hours long.
+func (dr DateRange) Days() int {
+	start := time.Date(dr.Start.Year(), dr.Start.Month(), dr.Start.Day(), 0, 0, 0, 0, time.UTC)
+	end := time.Date(dr.End.Year(), dr.End.Month(), dr.End.Day(), 0, 0, 0, 0, time.UTC)
+	return int(end.Sub(start).Hours()/24) + 1
+}
+
+// StartsAt is the UTC instant the range begins.
+func (dr DateRange) StartsAt() time.Time {
+	return dr.Start.UTC()
+}
+
+// EndsBefore is the UTC instant the day after the range ends begins. Queries
+// should treat it as an exclusive upper bound.
+func (dr DateRange) EndsBefore() time.Time {
+	return dr.End.AddDate(0, 0, 1).UTC()
+}
+
+func (dr DateRange) TimeZone() string {
+	if dr.Location == nil {
+		return "UTC"
+	}
+
+	return dr.Location.String()
+}
+
+func (dr DateRange) StartDate() string {
+	return dr.Start.Format(dateFormat)
+}
+
+func (dr DateRange) EndDate() string {
+	return dr.End.Format(dateFormat)
+}
+
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
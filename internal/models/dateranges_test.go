@@ -0,0 +1,236 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+)
+
+func TestNewDateRange(t *testing.T) {
+	today := time.Date(2025, 5, 14, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		preset    string
+		wantStart string
+		wantDays  int
+	}{
+		{preset: RangeToday, wantStart: "2025-05-14", wantDays: 1},
+		{preset: Range7Days, wantStart: "2025-05-08", wantDays: 7},
+		{preset: Range30Days, wantStart: "2025-04-15", wantDays: 30},
+		{preset: Range90Days, wantStart: "2025-02-14", wantDays: 90},
+		{preset: RangeMonthToDate, wantStart: "2025-05-01", wantDays: 14},
+		{preset: RangeYearToDate, wantStart: "2025-01-01", wantDays: 134},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.preset, func(t *testing.T) {
+			dr, err := NewDateRange(tc.preset, today, time.UTC)
+			assert.Nil(t, err)
+			assert.Equal(t, dr.StartDate(), tc.wantStart)
+			assert.Equal(t, dr.EndDate(), "2025-05-14")
+			assert.Equal(t, dr.Days(), tc.wantDays)
+		})
+	}
+
+	t.Run("Unknown preset", func(t *testing.T) {
+		_, err := NewDateRange("forever", today, time.UTC)
+		assert.Equal(t, err, ErrInvalidDateRange)
+	})
+}
+
+func TestNewCustomDateRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		wantErr  error
+		wantDays int
+	}{
+		{name: "Valid", from: "2025-01-01", to: "2025-01-31", wantDays: 31},
+		{name: "Single day", from: "2025-01-01", to: "2025-01-01", wantDays: 1},
+		{name: "End before start", from: "2025-01-31", to: "2025-01-01", wantErr: ErrInvalidDateRange},
+		{name: "Invalid date", from: "2025-13-01", to: "2025-01-01", wantErr: ErrInvalidDateRange},
+		{name: "Too long", from: "2000-01-01", to: "2025-01-01", wantErr: ErrInvalidDateRange},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dr, err := NewCustomDateRange(tc.from, tc.to, time.UTC)
+			assert.Equal(t, err, tc.wantErr)
+
+			if tc.wantErr == nil {
+				assert.Equal(t, dr.Days(), tc.wantDays)
+				assert.Equal(t, dr.Preset, RangeCustom)
+			}
+		})
+	}
+}
+
+func TestDateRangeInTimeZone(t *testing.T) {
+	vancouver, err := time.LoadLocation("America/Vancouver")
+	assert.Nil(t, err)
+
+	t.Run("Today rolls over at local midnight", func(t *testing.T) {
+		// 4:30pm in Vancouver is already the next day in UTC.
+		now := time.Date(2025, 5, 15, 0, 30, 0, 0, time.UTC)
+
+		dr, err := NewDateRange(RangeToday, now, vancouver)
+		assert.Nil(t, err)
+		assert.Equal(t, dr.StartDate(), "2025-05-14")
+		assert.Equal(t, dr.StartsAt(), time.Date(2025, 5, 14, 7, 0, 0, 0, time.UTC))
+		assert.Equal(t, dr.EndsBefore(), time.Date(2025, 5, 15, 7, 0, 0, 0, time.UTC))
+		assert.Equal(t, dr.TimeZone(), "America/Vancouver")
+	})
+
+	t.Run("Across a DST transition", func(t *testing.T) {
+		dr, err := NewCustomDateRange("2025-03-08", "2025-03-10", vancouver)
+		assert.Nil(t, err)
+		assert.Equal(t, dr.Days(), 3)
+		assert.Equal(t, dr.StartsAt(), time.Date(2025, 3, 8, 8, 0, 0, 0, time.UTC))
+		assert.Equal(t, dr.EndsBefore(), time.Date(2025, 3, 11, 7, 0, 0, 0, time.UTC))
+	})
+}
+
+func TestDateRangeGranularity(t *testing.T) {
+	vancouver, err := time.LoadLocation("America/Vancouver")
+	assert.Nil(t, err)
+
+	now := time.Date(2025, 5, 14, 15, 30, 0, 0, time.UTC)
+
+	t.Run("Defaults", func(t *testing.T) {
+		tests := []struct {
+			preset string
+			want   string
+		}{
+			{preset: RangeToday, want: GranularityHour},
+			{preset: Range7Days, want: GranularityDay},
+			{preset: Range90Days, want: GranularityDay},
+			{preset: RangeYearToDate, want: GranularityWeek},
+		}
+
+		for _, tc := range tests {
+			dr, err := NewDateRange(tc.preset, now, time.UTC)
+			assert.Nil(t, err)
+			assert.Equal(t, dr.Granularity, tc.want)
+		}
+
+		dr, err := NewCustomDateRange("2022-01-01", "2025-01-01", time.UTC)
+		assert.Nil(t, err)
+		assert.Equal(t, dr.Granularity, GranularityMonth)
+	})
+
+	t.Run("Buckets", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			from        string
+			to          string
+			loc         *time.Location
+			granularity string
+			wantCount   int
+			wantFirst   string
+			wantLast    string
+		}{
+			{
+				name:        "Minutes in a day",
+				from:        "2025-05-14",
+				to:          "2025-05-14",
+				loc:         time.UTC,
+				granularity: GranularityMinute,
+				wantCount:   1440,
+				wantFirst:   "2025-05-14 00:00",
+				wantLast:    "2025-05-14 23:59",
+			},
+			{
+				name:        "Hours on the day DST starts",
+				from:        "2025-03-09",
+				to:          "2025-03-09",
+				loc:         vancouver,
+				granularity: GranularityHour,
+				wantCount:   23,
+				wantFirst:   "2025-03-09 00:00",
+				wantLast:    "2025-03-09 23:00",
+			},
+			{
+				name:        "Weeks start on Monday",
+				from:        "2025-05-01",
+				to:          "2025-05-14",
+				loc:         time.UTC,
+				granularity: GranularityWeek,
+				wantCount:   3,
+				wantFirst:   "2025-04-28",
+				wantLast:    "2025-05-12",
+			},
+			{
+				name:        "Months",
+				from:        "2025-01-15",
+				to:          "2025-05-14",
+				loc:         time.UTC,
+				granularity: GranularityMonth,
+				wantCount:   5,
+				wantFirst:   "2025-01",
+				wantLast:    "2025-05",
+			},
+		}
+
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				dr, err := NewCustomDateRange(tc.from, tc.to, tc.loc)
+				assert.Nil(t, err)
+
+				dr, err = dr.WithGranularity(tc.granularity)
+				assert.Nil(t, err)
+
+				buckets := dr.Buckets()
+				assert.Equal(t, len(buckets), tc.wantCount)
+				assert.Equal(t, dr.BucketKey(buckets[0]), tc.wantFirst)
+				assert.Equal(t, dr.BucketKey(buckets[len(buckets)-1]), tc.wantLast)
+			})
+		}
+	})
+
+	t.Run("Too many buckets", func(t *testing.T) {
+		dr, err := NewDateRange(Range7Days, now, time.UTC)
+		assert.Nil(t, err)
+
+		_, err = dr.WithGranularity(GranularityMinute)
+		assert.Equal(t, err, ErrInvalidGranularity)
+	})
+
+	t.Run("Unknown granularity", func(t *testing.T) {
+		dr, err := NewDateRange(Range7Days, now, time.UTC)
+		assert.Nil(t, err)
+
+		_, err = dr.WithGranularity("decade")
+		assert.Equal(t, err, ErrInvalidGranularity)
+	})
+}
+
+func TestDateRangeCompareTo(t *testing.T) {
+	dr, err := NewCustomDateRange("2024-03-01", "2024-03-31", time.UTC)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		mode      string
+		wantStart string
+		wantEnd   string
+		wantErr   error
+	}{
+		{mode: ComparePrevious, wantStart: "2024-01-30", wantEnd: "2024-02-29"},
+		{mode: CompareYear, wantStart: "2023-03-01", wantEnd: "2023-03-31"},
+		{mode: "tomorrow", wantErr: ErrInvalidComparison},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.mode, func(t *testing.T) {
+			compare, err := dr.CompareTo(tc.mode)
+			assert.Equal(t, err, tc.wantErr)
+
+			if tc.wantErr == nil {
+				assert.Equal(t, compare.StartDate(), tc.wantStart)
+				assert.Equal(t, compare.EndDate(), tc.wantEnd)
+				assert.Equal(t, compare.Granularity, dr.Granularity)
+			}
+		})
+	}
+}
@@ -7,4 +7,12 @@ var (
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
 	ErrDuplicateToken     = errors.New("models: duplicate token")
+	ErrInvalidDateRange   = errors.New("models: invalid date range")
+	ErrInvalidGranularity = errors.New("models: invalid granularity")
+	ErrInvalidComparison  = errors.New("models: invalid comparison")
+	ErrInvalidFilter      = errors.New("models: invalid filter")
+	ErrDuplicateGoal      = errors.New("models: duplicate goal")
+	ErrInvalidFunnelStep  = errors.New("models: invalid funnel step")
+	ErrInvalidHostname    = errors.New("models: invalid hostname")
+	ErrInvalidIPAddress   = errors.New("models: invalid IP address")
 )
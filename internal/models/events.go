@@ -3,54 +3,217 @@ package models
 import (
 	"cmp"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 )
 
+// PageviewAction is the action eh.js sends when a page is viewed.
+const PageviewAction = "pageview"
+
+// VisitTimeout is how long a visitor can be inactive before their next event
+// starts a new visit.
+const VisitTimeout = 30 * time.Minute
+
+// insertManyChunkSize keeps multi-row inserts well below Postgres' limit of
+// 65535 bind parameters per statement.
+const insertManyChunkSize = 500
+
+var eventInsertColumns = []string{
+	"site_id", "action", "count", "device_type", "os", "browser", "referrer", "properties", "visitor_id", "hostname", "path",
+	"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content",
+	"referrer_url", "referrer_source", "channel", "country", "region", "city", "created_at",
+}
+
 type Event struct {
-	ID         int64
-	SiteID     int64
-	Action     string
-	Count      int
-	DeviceType string
-	OS         string
-	Browser    string
-	Referrer   sql.NullString
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
+	ID             int64
+	SiteID         int64
+	Action         string
+	Count          int
+	DeviceType     string
+	OS             string
+	Browser        string
+	Referrer       sql.NullString
+	ReferrerURL    string
+	ReferrerSource string
+	Channel        string
+	Country        string
+	Region         string
+	City           string
+	Properties     EventProperties
+	VisitorID      sql.NullString
+	Hostname       string
+	Path           string
+	UTMSource      string
+	UTMMedium      string
+	UTMCampaign    string
+	UTMTerm        string
+	UTMContent     string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// EventProperties are the custom key/value pairs attached to an event. Values
+// are limited to strings, numbers and booleans.
+type EventProperties map[string]any
+
+func (p EventProperties) Value() (driver.Value, error) {
+	if p == nil {
+		return "{}", nil
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
 }
 
 type EventRepoInterface interface {
 	Insert(event *Event) error
-	CountsByDate(site *Site) (map[string]int, error)
-	MetricCounts(site *Site) (EventMetrics, error)
+	InsertMany(events []*Event) error
+	CountsByDate(site *Site, q EventQuery) (map[string]int, error)
+	MetricCounts(site *Site, q EventQuery) (EventMetrics, error)
+	Totals(site *Site, q EventQuery) (EventTotals, error)
+	VisitStats(site *Site, q EventQuery) (VisitStats, error)
+	GoalConversions(site *Site, q EventQuery, goals []Goal) ([]GoalConversion, error)
+	FunnelCounts(site *Site, q EventQuery, funnel Funnel) ([]FunnelStepCount, error)
+	Realtime(site *Site, since time.Time) (RealtimeStats, error)
+	ActionCounts(site *Site, q EventQuery) ([]ActionCount, error)
+	PropertyKeys(site *Site, q EventQuery) ([]string, error)
+	PropertyBreakdown(site *Site, q EventQuery, key string) (map[string]int, error)
+}
+
+// insertValues uses CreatedAt when it's set, since queued events may be
+// inserted a little while after they were received.
+func (e *Event) insertValues() []any {
+	createdAt := e.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	return []any{
+		e.SiteID, e.Action, e.Count, e.DeviceType, e.OS, e.Browser, e.Referrer, e.Properties, e.VisitorID, e.Hostname, e.Path,
+		e.UTMSource, e.UTMMedium, e.UTMCampaign, e.UTMTerm, e.UTMContent,
+		e.ReferrerURL, e.ReferrerSource, e.Channel, e.Country, e.Region, e.City, createdAt.UTC(),
+	}
+}
+
+// ActionCount is the number of events recorded for an action along with the
+// sum of their counts.
+type ActionCount struct {
+	Action string
+	Events int
+	Total  int
+}
+
+// EventTotals summarizes the events matching a query. Visitor IDs change
+// daily, so a visitor who returns on another day is counted again.
+type EventTotals struct {
+	Events   int
+	Count    int
+	Visitors int
+}
+
+func (t EventTotals) EventsPerVisitor() float64 {
+	if t.Visitors == 0 {
+		return 0
+	}
+
+	return float64(t.Events) / float64(t.Visitors)
+}
+
+// VisitStats summarizes visits: a visitor's consecutive events with no more
+// than VisitTimeout between them. Visits are built from the events matching
+// a query, so one that began before the range starts is cut off at its start.
+type VisitStats struct {
+	Visits       int
+	Bounces      int
+	Pageviews    int
+	Duration     time.Duration
+	EntryActions map[string]int
+	ExitActions  map[string]int
 }
 
+// BounceRate is the percentage of visits with only a single event.
+func (s VisitStats) BounceRate() float64 {
+	if s.Visits == 0 {
+		return 0
+	}
+
+	return float64(s.Bounces) / float64(s.Visits) * 100
+}
+
+func (s VisitStats) AverageDuration() time.Duration {
+	if s.Visits == 0 {
+		return 0
+	}
+
+	return (s.Duration / time.Duration(s.Visits)).Round(time.Second)
+}
+
+func (s VisitStats) PagesPerVisit() float64 {
+	if s.Visits == 0 {
+		return 0
+	}
+
+	return float64(s.Pageviews) / float64(s.Visits)
+}
+
+// EventMetrics breaks events down by each dimension. Pages only counts
+// pageviews, and EntryPages counts the first page of each visit. Events
+// without campaign parameters or a location are left out of those
+// breakdowns.
 type EventMetrics struct {
-	DeviceType map[string]int
-	OS         map[string]int
-	Browser    map[string]int
-	Referrer   map[string]int
+	DeviceType  map[string]int
+	OS          map[string]int
+	Browser     map[string]int
+	Referrer    map[string]int
+	Channel     map[string]int
+	Country     map[string]int
+	Region      map[string]int
+	City        map[string]int
+	Hostname    map[string]int
+	Pages       map[string]int
+	EntryPages  map[string]int
+	UTMSource   map[string]int
+	UTMMedium   map[string]int
+	UTMCampaign map[string]int
 }
 
 func (em *EventMetrics) ToJSON() (map[string]string, error) {
-	deviceTypeJSON, deviceTypeErr := json.Marshal(em.DeviceType)
-	osJSON, osErr := json.Marshal(em.OS)
-	browserJSON, browserErr := json.Marshal(em.Browser)
-	referrerJSON, referrerErr := json.Marshal(em.Referrer)
+	metrics := map[string]map[string]int{
+		"deviceType":  em.DeviceType,
+		"os":          em.OS,
+		"browser":     em.Browser,
+		"referrer":    em.Referrer,
+		"channel":     em.Channel,
+		"country":     em.Country,
+		"region":      em.Region,
+		"city":        em.City,
+		"hostname":    em.Hostname,
+		"pages":       em.Pages,
+		"entryPages":  em.EntryPages,
+		"utmSource":   em.UTMSource,
+		"utmMedium":   em.UTMMedium,
+		"utmCampaign": em.UTMCampaign,
+	}
 
-	firstError := cmp.Or(deviceTypeErr, osErr, browserErr, referrerErr)
-	if firstError != nil {
-		return nil, firstError
+	out := make(map[string]string, len(metrics))
+	for key, counts := range metrics {
+		countsJSON, err := json.Marshal(counts)
+		if err != nil {
+			return nil, err
+		}
+
+		out[key] = string(countsJSON)
 	}
 
-	return map[string]string{
-		"deviceType": string(deviceTypeJSON),
-		"os":         string(osJSON),
-		"browser":    string(browserJSON),
-		"referrer":   string(referrerJSON),
-	}, nil
+	return out, nil
 }
 
 type EventRepo struct {
@@ -58,11 +221,8 @@ type EventRepo struct {
 }
 
 func (r *EventRepo) Insert(event *Event) error {
-	stmt := `INSERT INTO events (site_id, action, count, device_type, os, browser, referrer)
-	VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at, updated_at;`
-
 	err := r.db.
-		QueryRow(stmt, event.SiteID, event.Action, event.Count, event.DeviceType, event.OS, event.Browser, event.Referrer).
+		QueryRow(eventInsertStmt(1), event.insertValues()...).
 		Scan(&event.ID, &event.CreatedAt, &event.UpdatedAt)
 
 	if err != nil {
@@ -72,42 +232,100 @@ func (r *EventRepo) Insert(event *Event) error {
 	return nil
 }
 
-func (r *EventRepo) CountsByDate(site *Site) (map[string]int, error) {
-	days := 7
-	endOn := time.Now().UTC().Truncate(24 * time.Hour)
-	startOn := endOn.AddDate(0, 0, -(days - 1))
+func (r *EventRepo) InsertMany(events []*Event) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("[EventRepo.InsertMany] %w", err)
+	}
+	defer tx.Rollback()
 
-	out := make(map[string]int, days)
-	for d := range days {
-		t := startOn.AddDate(0, 0, d)
-		out[t.Format("2006-01-02")] = 0
+	for chunk := range slices.Chunk(events, insertManyChunkSize) {
+		args := make([]any, 0, len(chunk)*len(eventInsertColumns))
+		for _, event := range chunk {
+			args = append(args, event.insertValues()...)
+		}
+
+		rows, err := tx.Query(eventInsertStmt(len(chunk)), args...)
+		if err != nil {
+			return fmt.Errorf("[EventRepo.InsertMany] %w", err)
+		}
+
+		i := 0
+		for rows.Next() {
+			err = rows.Scan(&chunk[i].ID, &chunk[i].CreatedAt, &chunk[i].UpdatedAt)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("[EventRepo.InsertMany] %w", err)
+			}
+			i++
+		}
+
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return fmt.Errorf("[EventRepo.InsertMany] %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("[EventRepo.InsertMany] %w", err)
+	}
+
+	return nil
+}
+
+func eventInsertStmt(rowCount int) string {
+	columnCount := len(eventInsertColumns)
+	rows := make([]string, rowCount)
+	for i := range rowCount {
+		placeholders := make([]string, columnCount)
+		for j := range columnCount {
+			placeholders[j] = fmt.Sprintf("$%d", i*columnCount+j+1)
+		}
+
+		rows[i] = "(" + strings.Join(placeholders, ", ") + ")"
 	}
 
-	stmt := `
-		SELECT DATE_TRUNC('day', created_at)::DATE, COUNT(*)
+	return fmt.Sprintf(
+		"INSERT INTO events (%s) VALUES %s RETURNING id, created_at, updated_at;",
+		strings.Join(eventInsertColumns, ", "),
+		strings.Join(rows, ", "),
+	)
+}
+
+func (r *EventRepo) CountsByDate(site *Site, q EventQuery) (map[string]int, error) {
+	dr := q.Range
+	buckets := dr.Buckets()
+	out := make(map[string]int, len(buckets))
+	for _, t := range buckets {
+		out[dr.BucketKey(t)] = 0
+	}
+
+	where, args := q.whereClause(site, []any{dr.Granularity, dr.TimeZone()})
+	stmt := fmt.Sprintf(`
+		SELECT DATE_TRUNC($1, created_at AT TIME ZONE 'UTC' AT TIME ZONE $2), COUNT(*)
 		FROM events
-		WHERE site_id = $1
-			AND created_at::DATE BETWEEN $2::DATE AND $3::DATE
-		GROUP BY DATE_TRUNC('day', created_at)
-		ORDER BY DATE_TRUNC('day', created_at);
-	`
+		WHERE %s
+		GROUP BY 1
+		ORDER BY 1;
+	`, where)
 
-	rows, err := r.db.Query(stmt, site.ID, startOn, endOn)
+	rows, err := r.db.Query(stmt, args...)
 	if err != nil {
 		return out, fmt.Errorf("[EventRepo.CountsByDate] %w", err)
 	}
 
 	defer rows.Close()
 	for rows.Next() {
-		var date time.Time
+		var bucket time.Time
 		var count int
 
-		err := rows.Scan(&date, &count)
+		err := rows.Scan(&bucket, &count)
 		if err != nil {
 			return out, fmt.Errorf("[EventRepo.CountsByDate] %w", err)
 		}
 
-		out[date.Format("2006-01-02")] = count
+		out[dr.BucketKey(bucket)] = count
 	}
 
 	if err = rows.Err(); err != nil {
@@ -117,17 +335,14 @@ func (r *EventRepo) CountsByDate(site *Site) (map[string]int, error) {
 	return out, nil
 }
 
-func (r *EventRepo) MetricCounts(site *Site) (EventMetrics, error) {
-	days := 7
-	endOn := time.Now().UTC().Truncate(24 * time.Hour)
-	startOn := endOn.AddDate(0, 0, -(days - 1))
-
-	stmt := `
+func (r *EventRepo) MetricCounts(site *Site, q EventQuery) (EventMetrics, error) {
+	where, args := q.whereClause(site, []any{VisitTimeout.Seconds(), PageviewAction})
+	stmt := fmt.Sprintf(`
 WITH filtered_events AS (
 	SELECT * FROM events
-	WHERE site_id = $1
-		AND created_at::DATE BETWEEN $2 AND $3
-)
+	WHERE %[1]s
+),
+%[2]s
 SELECT device_type, count(*), 'device_type' AS metric
 FROM filtered_events
 GROUP BY device_type
@@ -140,19 +355,82 @@ SELECT browser, count(*), 'browser' AS metric
 FROM filtered_events
 GROUP BY browser
 UNION
-SELECT referrer, count(*), 'referrer' AS metric
+SELECT %[3]s, count(*), 'referrer' AS metric
+FROM filtered_events
+GROUP BY 1
+UNION
+SELECT channel, count(*), 'channel' AS metric
+FROM filtered_events
+GROUP BY channel
+UNION
+SELECT hostname, count(*), 'hostname' AS metric
+FROM filtered_events
+WHERE hostname <> ''
+GROUP BY hostname
+UNION
+SELECT path, count(*), 'page' AS metric
+FROM filtered_events
+WHERE action = $2 AND path <> ''
+GROUP BY path
+UNION
+SELECT entry_page, count(*), 'entry_page' AS metric
+FROM (
+	SELECT (ARRAY_AGG(path ORDER BY created_at, id) FILTER (WHERE path <> ''))[1] AS entry_page
+	FROM numbered_events
+	GROUP BY visitor_id, visit_number
+) AS visits
+WHERE entry_page IS NOT NULL
+GROUP BY entry_page
+UNION
+SELECT utm_source, count(*), 'utm_source' AS metric
 FROM filtered_events
-GROUP BY referrer;
-`
+WHERE utm_source <> ''
+GROUP BY utm_source
+UNION
+SELECT utm_medium, count(*), 'utm_medium' AS metric
+FROM filtered_events
+WHERE utm_medium <> ''
+GROUP BY utm_medium
+UNION
+SELECT utm_campaign, count(*), 'utm_campaign' AS metric
+FROM filtered_events
+WHERE utm_campaign <> ''
+GROUP BY utm_campaign
+UNION
+SELECT country, count(*), 'country' AS metric
+FROM filtered_events
+WHERE country <> ''
+GROUP BY country
+UNION
+SELECT region, count(*), 'region' AS metric
+FROM filtered_events
+WHERE region <> ''
+GROUP BY region
+UNION
+SELECT city, count(*), 'city' AS metric
+FROM filtered_events
+WHERE city <> ''
+GROUP BY city;
+`, where, visitEventsSQL(where), filterColumns[DimensionReferrer])
 
 	out := EventMetrics{
-		DeviceType: make(map[string]int),
-		OS:         make(map[string]int),
-		Browser:    make(map[string]int),
-		Referrer:   make(map[string]int),
+		DeviceType:  make(map[string]int),
+		OS:          make(map[string]int),
+		Browser:     make(map[string]int),
+		Referrer:    make(map[string]int),
+		Channel:     make(map[string]int),
+		Country:     make(map[string]int),
+		Region:      make(map[string]int),
+		City:        make(map[string]int),
+		Hostname:    make(map[string]int),
+		Pages:       make(map[string]int),
+		EntryPages:  make(map[string]int),
+		UTMSource:   make(map[string]int),
+		UTMMedium:   make(map[string]int),
+		UTMCampaign: make(map[string]int),
 	}
 
-	rows, err := r.db.Query(stmt, site.ID, startOn, endOn)
+	rows, err := r.db.Query(stmt, args...)
 	if err != nil {
 		return out, fmt.Errorf("[EventRepo.MetricCounts] %w", err)
 	}
@@ -176,11 +454,27 @@ GROUP BY referrer;
 		case "browser":
 			out.Browser[*value] += count
 		case "referrer":
-			key := "direct"
-			if value != nil {
-				key = *value
-			}
-			out.Referrer[key] += count
+			out.Referrer[*value] += count
+		case "channel":
+			out.Channel[*value] += count
+		case "country":
+			out.Country[*value] += count
+		case "region":
+			out.Region[*value] += count
+		case "city":
+			out.City[*value] += count
+		case "hostname":
+			out.Hostname[*value] += count
+		case "page":
+			out.Pages[*value] += count
+		case "entry_page":
+			out.EntryPages[*value] += count
+		case "utm_source":
+			out.UTMSource[*value] += count
+		case "utm_medium":
+			out.UTMMedium[*value] += count
+		case "utm_campaign":
+			out.UTMCampaign[*value] += count
 		default:
 			panic("Unknown metric: " + metric)
 		}
@@ -192,3 +486,324 @@ GROUP BY referrer;
 
 	return out, nil
 }
+
+func (r *EventRepo) Totals(site *Site, q EventQuery) (EventTotals, error) {
+	where, args := q.whereClause(site, nil)
+	stmt := fmt.Sprintf(`
+		SELECT COUNT(*), COALESCE(SUM(count), 0), COUNT(DISTINCT visitor_id)
+		FROM events
+		WHERE %s;
+	`, where)
+
+	var t EventTotals
+	err := r.db.QueryRow(stmt, args...).Scan(&t.Events, &t.Count, &t.Visitors)
+	if err != nil {
+		return t, fmt.Errorf("[EventRepo.Totals] %w", err)
+	}
+
+	return t, nil
+}
+
+func (r *EventRepo) VisitStats(site *Site, q EventQuery) (VisitStats, error) {
+	where, args := q.whereClause(site, []any{VisitTimeout.Seconds(), PageviewAction})
+	stmt := fmt.Sprintf(`
+		WITH %s,
+		visits AS (
+			SELECT
+				(ARRAY_AGG(action ORDER BY created_at, id))[1] AS entry_action,
+				(ARRAY_AGG(action ORDER BY created_at DESC, id DESC))[1] AS exit_action,
+				COUNT(*) AS events,
+				COUNT(*) FILTER (WHERE action = $2) AS pageviews,
+				EXTRACT(EPOCH FROM MAX(created_at) - MIN(created_at)) AS duration
+			FROM numbered_events
+			GROUP BY visitor_id, visit_number
+		)
+		SELECT entry_action, exit_action, COUNT(*), COUNT(*) FILTER (WHERE events = 1), SUM(pageviews), SUM(duration)
+		FROM visits
+		GROUP BY entry_action, exit_action;
+	`, visitEventsSQL(where))
+
+	out := VisitStats{EntryActions: make(map[string]int), ExitActions: make(map[string]int)}
+	rows, err := r.db.Query(stmt, args...)
+	if err != nil {
+		return out, fmt.Errorf("[EventRepo.VisitStats] %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entryAction, exitAction string
+		var visits, bounces, pageviews int
+		var duration float64
+
+		err := rows.Scan(&entryAction, &exitAction, &visits, &bounces, &pageviews, &duration)
+		if err != nil {
+			return out, fmt.Errorf("[EventRepo.VisitStats] %w", err)
+		}
+
+		out.Visits += visits
+		out.Bounces += bounces
+		out.Pageviews += pageviews
+		out.Duration += time.Duration(duration * float64(time.Second))
+		out.EntryActions[entryAction] += visits
+		out.ExitActions[exitAction] += visits
+	}
+
+	if err := rows.Err(); err != nil {
+		return out, fmt.Errorf("[EventRepo.VisitStats] %w", err)
+	}
+
+	return out, nil
+}
+
+// visitEventsSQL builds CTEs that number each visitor's events by visit,
+// ending with numbered_events. The query's first arg must be VisitTimeout in
+// seconds.
+func visitEventsSQL(where string) string {
+	return fmt.Sprintf(`
+		visitor_events AS (
+			SELECT id, visitor_id, action, path, created_at,
+				CASE
+					WHEN LAG(created_at) OVER visitor IS NULL THEN 1
+					WHEN EXTRACT(EPOCH FROM created_at - LAG(created_at) OVER visitor) > $1 THEN 1
+					ELSE 0
+				END AS starts_visit
+			FROM events
+			WHERE visitor_id IS NOT NULL
+				AND %s
+			WINDOW visitor AS (PARTITION BY visitor_id ORDER BY created_at, id)
+		),
+		numbered_events AS (
+			SELECT *, SUM(starts_visit) OVER (PARTITION BY visitor_id ORDER BY created_at, id) AS visit_number
+			FROM visitor_events
+		)`, where)
+}
+
+// GoalConversions counts how often each goal was completed, returned in the
+// same order as goals.
+func (r *EventRepo) GoalConversions(site *Site, q EventQuery, goals []Goal) ([]GoalConversion, error) {
+	if len(goals) == 0 {
+		return nil, nil
+	}
+
+	// A goal without any events still joins to one row of NULLs. COUNT skips
+	// NULL columns but not a row of them, so visits are filtered explicitly.
+	ids, dimensions, values := goalArrays(goals)
+	where, args := q.whereClause(site, []any{VisitTimeout.Seconds(), ids, dimensions, values})
+	stmt := fmt.Sprintf(`
+		WITH %s
+		SELECT
+			goals.id,
+			COUNT(e.id),
+			COUNT(DISTINCT e.visitor_id),
+			COUNT(DISTINCT (e.visitor_id, e.visit_number)) FILTER (WHERE e.id IS NOT NULL)
+		FROM UNNEST($2::BIGINT[], $3::TEXT[], $4::TEXT[]) AS goals(id, dimension, value)
+		LEFT JOIN numbered_events e
+			ON (goals.dimension = 'action' AND e.action = goals.value)
+			OR (goals.dimension = 'path' AND e.path = goals.value)
+		GROUP BY goals.id;
+	`, visitEventsSQL(where))
+
+	rows, err := r.db.Query(stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("[EventRepo.GoalConversions] %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]GoalConversion, len(goals))
+	for rows.Next() {
+		var id int64
+		var c GoalConversion
+		err := rows.Scan(&id, &c.Events, &c.Visitors, &c.Visits)
+		if err != nil {
+			return nil, fmt.Errorf("[EventRepo.GoalConversions] %w", err)
+		}
+
+		counts[id] = c
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[EventRepo.GoalConversions] %w", err)
+	}
+
+	out := make([]GoalConversion, len(goals))
+	for i, g := range goals {
+		out[i] = counts[g.ID]
+		out[i].Goal = g
+	}
+
+	return out, nil
+}
+
+// FunnelCounts counts the visitors who reached each step of funnel, in order,
+// with every step after the first reached within the funnel's window of the
+// visitor's first step.
+func (r *EventRepo) FunnelCounts(site *Site, q EventQuery, funnel Funnel) ([]FunnelStepCount, error) {
+	if len(funnel.Steps) == 0 {
+		return nil, nil
+	}
+
+	where, args := q.whereClause(site, []any{funnel.Window.Seconds()})
+	ctes := []string{fmt.Sprintf(`
+		filtered AS (
+			SELECT id, visitor_id, action, properties, created_at
+			FROM events
+			WHERE visitor_id IS NOT NULL
+				AND %s
+		)`, where)}
+	counts := make([]string, len(funnel.Steps))
+
+	for i, step := range funnel.Steps {
+		var condition string
+		condition, args = funnelStepCondition(step, args)
+
+		if i == 0 {
+			ctes = append(ctes, fmt.Sprintf(`
+		step_1 AS (
+			SELECT DISTINCT ON (e.visitor_id) e.visitor_id, e.created_at AS started_at, e.created_at AS reached_at, e.id AS reached_id
+			FROM filtered e
+			WHERE %s
+			ORDER BY e.visitor_id, e.created_at, e.id
+		)`, condition))
+		} else {
+			ctes = append(ctes, fmt.Sprintf(`
+		step_%[1]d AS (
+			SELECT DISTINCT ON (e.visitor_id) e.visitor_id, s.started_at, e.created_at AS reached_at, e.id AS reached_id
+			FROM step_%[2]d s
+			JOIN filtered e ON e.visitor_id = s.visitor_id
+				AND (e.created_at, e.id) > (s.reached_at, s.reached_id)
+				AND e.created_at <= s.started_at + make_interval(secs => $1)
+			WHERE %[3]s
+			ORDER BY e.visitor_id, e.created_at, e.id
+		)`, i+1, i, condition))
+		}
+
+		counts[i] = fmt.Sprintf("SELECT %[1]d, COUNT(*) FROM step_%[1]d", i+1)
+	}
+
+	stmt := fmt.Sprintf("WITH %s\n%s;", strings.Join(ctes, ","), strings.Join(counts, "\nUNION ALL\n"))
+	rows, err := r.db.Query(stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("[EventRepo.FunnelCounts] %w", err)
+	}
+	defer rows.Close()
+
+	visitors := make([]int, len(funnel.Steps))
+	for rows.Next() {
+		var step, count int
+		err := rows.Scan(&step, &count)
+		if err != nil {
+			return nil, fmt.Errorf("[EventRepo.FunnelCounts] %w", err)
+		}
+
+		visitors[step-1] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[EventRepo.FunnelCounts] %w", err)
+	}
+
+	return NewFunnelStepCounts(funnel.Steps, visitors), nil
+}
+
+func (r *EventRepo) ActionCounts(site *Site, q EventQuery) ([]ActionCount, error) {
+	where, args := q.whereClause(site, nil)
+	stmt := fmt.Sprintf(`
+		SELECT action, COUNT(*), COALESCE(SUM(count), 0)
+		FROM events
+		WHERE %s
+		GROUP BY action
+		ORDER BY 2 DESC, 1;
+	`, where)
+
+	var counts []ActionCount
+	rows, err := r.db.Query(stmt, args...)
+	if err != nil {
+		return counts, fmt.Errorf("[EventRepo.ActionCounts] %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ac ActionCount
+		err := rows.Scan(&ac.Action, &ac.Events, &ac.Total)
+		if err != nil {
+			return counts, fmt.Errorf("[EventRepo.ActionCounts] %w", err)
+		}
+
+		counts = append(counts, ac)
+	}
+
+	if err := rows.Err(); err != nil {
+		return counts, fmt.Errorf("[EventRepo.ActionCounts] %w", err)
+	}
+
+	return counts, nil
+}
+
+func (r *EventRepo) PropertyKeys(site *Site, q EventQuery) ([]string, error) {
+	where, args := q.whereClause(site, nil)
+	stmt := fmt.Sprintf(`
+		SELECT DISTINCT jsonb_object_keys(properties)
+		FROM events
+		WHERE %s
+		ORDER BY 1;
+	`, where)
+
+	var keys []string
+	rows, err := r.db.Query(stmt, args...)
+	if err != nil {
+		return keys, fmt.Errorf("[EventRepo.PropertyKeys] %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		err := rows.Scan(&key)
+		if err != nil {
+			return keys, fmt.Errorf("[EventRepo.PropertyKeys] %w", err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return keys, fmt.Errorf("[EventRepo.PropertyKeys] %w", err)
+	}
+
+	return keys, nil
+}
+
+func (r *EventRepo) PropertyBreakdown(site *Site, q EventQuery, key string) (map[string]int, error) {
+	where, args := q.whereClause(site, []any{key})
+	stmt := fmt.Sprintf(`
+		SELECT properties->>$1, COUNT(*)
+		FROM events
+		WHERE properties ? $1
+			AND %s
+		GROUP BY properties->>$1;
+	`, where)
+
+	out := make(map[string]int)
+	rows, err := r.db.Query(stmt, args...)
+	if err != nil {
+		return out, fmt.Errorf("[EventRepo.PropertyBreakdown] %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var value sql.NullString
+		var count int
+
+		err := rows.Scan(&value, &count)
+		if err != nil {
+			return out, fmt.Errorf("[EventRepo.PropertyBreakdown] %w", err)
+		}
+
+		out[cmp.Or(value.String, "(none)")] += count
+	}
+
+	if err := rows.Err(); err != nil {
+		return out, fmt.Errorf("[EventRepo.PropertyBreakdown] %w", err)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,424 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+)
+
+func TestEventRepoInsertMany(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	site, r := setupEventRepo(t)
+
+	events := make([]*Event, insertManyChunkSize+1)
+	for i := range events {
+		events[i] = &Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: testNow.Add(-time.Duration(i) * time.Second)}
+	}
+	insertEvents(t, r, events...)
+
+	ids := make(map[int64]bool)
+	for _, e := range events {
+		assert.NotEqual(t, e.ID, 0)
+		ids[e.ID] = true
+	}
+	assert.Equal(t, len(ids), len(events))
+	assert.Equal(t, events[len(events)-1].CreatedAt.Equal(testNow.Add(-insertManyChunkSize*time.Second)), true)
+
+	var count int
+	assert.Nil(t, r.db.QueryRow("SELECT COUNT(*) FROM events WHERE site_id = $1;", site.ID).Scan(&count))
+	assert.Equal(t, count, len(events))
+
+	t.Run("No events", func(t *testing.T) {
+		assert.Nil(t, r.InsertMany(nil))
+	})
+}
+
+func TestEventRepoInsertCreatedAt(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	site, r := setupEventRepo(t)
+
+	received := time.Now().Add(-time.Minute).Truncate(time.Microsecond)
+	queued := &Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: received}
+	assert.Nil(t, r.Insert(queued))
+	assert.Equal(t, queued.CreatedAt.Equal(received), true)
+
+	unset := &Event{SiteID: site.ID, Action: "pageview", Count: 1}
+	assert.Nil(t, r.Insert(unset))
+	assert.Equal(t, time.Since(unset.CreatedAt).Abs() < time.Minute, true)
+}
+
+func TestEventRepoProperties(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	site, r := setupEventRepo(t)
+	otherSite := setupSite(t, r.db)
+
+	insertEvents(t, r,
+		&Event{SiteID: site.ID, Action: "signup", Count: 1, Properties: EventProperties{"plan": "pro"}, CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "signup", Count: 1, Properties: EventProperties{"plan": "pro", "seats": 2}, CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "signup", Count: 1, Properties: EventProperties{"plan": nil}, CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "signup", Count: 1, Properties: EventProperties{"coupon": "old"}, CreatedAt: testNow.AddDate(0, 0, -1)},
+		&Event{SiteID: otherSite.ID, Action: "signup", Count: 1, Properties: EventProperties{"referral": "yes"}, CreatedAt: testNow},
+	)
+	q := todayQuery(t)
+
+	t.Run("Keys", func(t *testing.T) {
+		keys, err := r.PropertyKeys(site, q)
+		assert.Nil(t, err)
+		assert.Equal(t, strings.Join(keys, ","), "plan,seats")
+	})
+
+	t.Run("Breakdown", func(t *testing.T) {
+		counts, err := r.PropertyBreakdown(site, q, "plan")
+		assert.Nil(t, err)
+		assert.Equal(t, len(counts), 2)
+		assert.Equal(t, counts["pro"], 2)
+		assert.Equal(t, counts["(none)"], 1)
+	})
+
+	t.Run("Number values", func(t *testing.T) {
+		counts, err := r.PropertyBreakdown(site, q, "seats")
+		assert.Nil(t, err)
+		assert.Equal(t, len(counts), 1)
+		assert.Equal(t, counts["2"], 1)
+	})
+
+	t.Run("Filtered", func(t *testing.T) {
+		counts, err := r.PropertyBreakdown(site, q.WithFilter(Filter{Dimension: DimensionAction, Op: FilterEquals, Value: "pageview"}), "plan")
+		assert.Nil(t, err)
+		assert.Equal(t, len(counts), 0)
+	})
+}
+
+func TestEventRepoCountsByDate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	site, r := setupEventRepo(t)
+	otherSite := setupSite(t, r.db)
+
+	at := func(day, hour int) time.Time { return time.Date(2026, 10, day, hour, 0, 0, 0, time.UTC) }
+	insertEvents(t, r,
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: at(18, 12)},
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: at(18, 0)},
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: at(17, 23)},
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: at(12, 0)},
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: at(11, 23)},
+		&Event{SiteID: otherSite.ID, Action: "pageview", Count: 1, CreatedAt: at(18, 12)},
+	)
+
+	dr, err := NewDateRange(Range7Days, testNow, time.UTC)
+	assert.Nil(t, err)
+
+	counts, err := r.CountsByDate(site, EventQuery{Range: dr})
+	assert.Nil(t, err)
+	assert.Equal(t, len(counts), 7)
+	assert.Equal(t, counts["2026-10-18"], 2)
+	assert.Equal(t, counts["2026-10-17"], 1)
+	assert.Equal(t, counts["2026-10-13"], 0)
+	assert.Equal(t, counts["2026-10-12"], 1)
+	_, ok := counts["2026-10-11"]
+	assert.Equal(t, ok, false)
+}
+
+func TestEventRepoCountsByDateInTimeZone(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	site, r := setupEventRepo(t)
+
+	vancouver, err := time.LoadLocation("America/Vancouver")
+	assert.Nil(t, err)
+
+	at := func(day, hour int) time.Time { return time.Date(2026, 10, day, hour, 0, 0, 0, time.UTC) }
+	insertEvents(t, r,
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: at(18, 12)},
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: at(18, 8)},
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: at(18, 3)},
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: at(12, 8)},
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: at(12, 6)},
+	)
+
+	dr, err := NewDateRange(Range7Days, testNow, vancouver)
+	assert.Nil(t, err)
+
+	counts, err := r.CountsByDate(site, EventQuery{Range: dr})
+	assert.Nil(t, err)
+	assert.Equal(t, len(counts), 7)
+	assert.Equal(t, counts["2026-10-18"], 2)
+	assert.Equal(t, counts["2026-10-17"], 1)
+	assert.Equal(t, counts["2026-10-12"], 1)
+	_, ok := counts["2026-10-11"]
+	assert.Equal(t, ok, false)
+}
+
+func TestEventRepoCountsByDateGranularity(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	site, r := setupEventRepo(t)
+
+	at := func(day, hour, minute int) time.Time { return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC) }
+	insertEvents(t, r,
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: at(18, 12, 0)},
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: at(18, 12, 30)},
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: at(18, 13, 5)},
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: at(13, 9, 0)},
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: at(1, 9, 0)},
+	)
+
+	tests := []struct {
+		name        string
+		preset      string
+		granularity string
+		buckets     int
+		want        map[string]int
+	}{
+		{name: "Minute", preset: RangeToday, granularity: GranularityMinute, buckets: 24 * 60, want: map[string]int{"2026-10-18 12:00": 1, "2026-10-18 12:30": 1, "2026-10-18 13:05": 1}},
+		{name: "Hour", preset: RangeToday, granularity: GranularityHour, buckets: 24, want: map[string]int{"2026-10-18 12:00": 2, "2026-10-18 13:00": 1}},
+		{name: "Week", preset: Range30Days, granularity: GranularityWeek, buckets: 5, want: map[string]int{"2026-10-12": 4, "2026-09-28": 1}},
+		{name: "Month", preset: RangeYearToDate, granularity: GranularityMonth, buckets: 10, want: map[string]int{"2026-10": 5, "2026-09": 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dr, err := NewDateRange(tt.preset, testNow, time.UTC)
+			assert.Nil(t, err)
+			dr, err = dr.WithGranularity(tt.granularity)
+			assert.Nil(t, err)
+
+			counts, err := r.CountsByDate(site, EventQuery{Range: dr})
+			assert.Nil(t, err)
+			assert.Equal(t, len(counts), tt.buckets)
+			for key, count := range tt.want {
+				assert.Equal(t, counts[key], count)
+			}
+		})
+	}
+}
+
+func TestEventRepoMetricCountsPages(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	site, r := setupEventRepo(t)
+
+	insertEvents(t, r,
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, VisitorID: visitor("a"), Hostname: "example.com", Path: "/", CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, VisitorID: visitor("a"), Hostname: "example.com", Path: "/pricing", CreatedAt: testNow.Add(time.Minute)},
+		&Event{SiteID: site.ID, Action: "signup", Count: 1, VisitorID: visitor("a"), Hostname: "example.com", Path: "/pricing", CreatedAt: testNow.Add(2 * time.Minute)},
+		&Event{SiteID: site.ID, Action: "click", Count: 1, VisitorID: visitor("b"), CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, VisitorID: visitor("b"), Hostname: "docs.example.com", Path: "/docs", CreatedAt: testNow.Add(time.Minute)},
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, Hostname: "www.example.com", Path: "/", CreatedAt: testNow},
+	)
+
+	metrics, err := r.MetricCounts(site, todayQuery(t))
+	assert.Nil(t, err)
+
+	assert.Equal(t, len(metrics.Hostname), 3)
+	assert.Equal(t, metrics.Hostname["example.com"], 3)
+	assert.Equal(t, metrics.Hostname["docs.example.com"], 1)
+	assert.Equal(t, metrics.Hostname["www.example.com"], 1)
+
+	assert.Equal(t, len(metrics.Pages), 3)
+	assert.Equal(t, metrics.Pages["/"], 2)
+	assert.Equal(t, metrics.Pages["/pricing"], 1)
+	assert.Equal(t, metrics.Pages["/docs"], 1)
+
+	assert.Equal(t, len(metrics.EntryPages), 2)
+	assert.Equal(t, metrics.EntryPages["/"], 1)
+	assert.Equal(t, metrics.EntryPages["/docs"], 1)
+}
+
+func TestEventRepoMetricCountsCampaigns(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	site, r := setupEventRepo(t)
+
+	insertEvents(t, r,
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, UTMSource: "newsletter", UTMMedium: "email", UTMCampaign: "launch", CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, UTMSource: "newsletter", UTMMedium: "email", UTMCampaign: "autumn", CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, UTMSource: "google", UTMMedium: "cpc", CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, CreatedAt: testNow},
+	)
+
+	metrics, err := r.MetricCounts(site, todayQuery(t))
+	assert.Nil(t, err)
+	assert.Equal(t, len(metrics.UTMSource), 2)
+	assert.Equal(t, metrics.UTMSource["newsletter"], 2)
+	assert.Equal(t, metrics.UTMSource["google"], 1)
+	assert.Equal(t, len(metrics.UTMMedium), 2)
+	assert.Equal(t, metrics.UTMMedium["email"], 2)
+	assert.Equal(t, metrics.UTMMedium["cpc"], 1)
+	assert.Equal(t, len(metrics.UTMCampaign), 2)
+	assert.Equal(t, metrics.UTMCampaign["launch"], 1)
+	assert.Equal(t, metrics.UTMCampaign["autumn"], 1)
+
+	t.Run("Filtered by source", func(t *testing.T) {
+		q := todayQuery(t).WithFilter(Filter{Dimension: DimensionUTMSource, Op: FilterEquals, Value: "google"})
+		metrics, err := r.MetricCounts(site, q)
+		assert.Nil(t, err)
+		assert.Equal(t, len(metrics.UTMCampaign), 0)
+		assert.Equal(t, metrics.UTMMedium["cpc"], 1)
+	})
+}
+
+func TestEventRepoMetricCountsChannels(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	site, r := setupEventRepo(t)
+
+	google := sql.NullString{String: "https://www.google.com/", Valid: true}
+	news := sql.NullString{String: "news.example.com", Valid: true}
+	insertEvents(t, r,
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, Referrer: google, ReferrerSource: "Google", Channel: "Organic Search", CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, Referrer: news, Channel: "Referral", CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, Referrer: news, Channel: "Referral", CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, Channel: "Direct", CreatedAt: testNow},
+	)
+
+	metrics, err := r.MetricCounts(site, todayQuery(t))
+	assert.Nil(t, err)
+	assert.Equal(t, len(metrics.Channel), 3)
+	assert.Equal(t, metrics.Channel["Organic Search"], 1)
+	assert.Equal(t, metrics.Channel["Referral"], 2)
+	assert.Equal(t, metrics.Channel["Direct"], 1)
+	assert.Equal(t, len(metrics.Referrer), 3)
+	assert.Equal(t, metrics.Referrer["Google"], 1)
+	assert.Equal(t, metrics.Referrer["news.example.com"], 2)
+	assert.Equal(t, metrics.Referrer["direct"], 1)
+
+	t.Run("Filtered by channel", func(t *testing.T) {
+		q := todayQuery(t).WithFilter(Filter{Dimension: DimensionChannel, Op: FilterEquals, Value: "Referral"})
+		metrics, err := r.MetricCounts(site, q)
+		assert.Nil(t, err)
+		assert.Equal(t, len(metrics.Referrer), 1)
+		assert.Equal(t, metrics.Referrer["news.example.com"], 2)
+	})
+}
+
+func TestEventRepoMetricCountsLocations(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	site, r := setupEventRepo(t)
+
+	insertEvents(t, r,
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, Country: "CA", Region: "British Columbia", City: "Vancouver", CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, Country: "CA", Region: "British Columbia", City: "Victoria", CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, Country: "DE", CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, CreatedAt: testNow},
+	)
+
+	metrics, err := r.MetricCounts(site, todayQuery(t))
+	assert.Nil(t, err)
+	assert.Equal(t, len(metrics.Country), 2)
+	assert.Equal(t, metrics.Country["CA"], 2)
+	assert.Equal(t, metrics.Country["DE"], 1)
+	assert.Equal(t, len(metrics.Region), 1)
+	assert.Equal(t, metrics.Region["British Columbia"], 2)
+	assert.Equal(t, len(metrics.City), 2)
+	assert.Equal(t, metrics.City["Vancouver"], 1)
+	assert.Equal(t, metrics.City["Victoria"], 1)
+
+	t.Run("Filtered by country", func(t *testing.T) {
+		q := todayQuery(t).WithFilter(Filter{Dimension: DimensionCountry, Op: FilterEquals, Value: "DE"})
+		metrics, err := r.MetricCounts(site, q)
+		assert.Nil(t, err)
+		assert.Equal(t, len(metrics.Country), 1)
+		assert.Equal(t, len(metrics.City), 0)
+	})
+}
+
+func TestEventRepoActionCounts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	site, r := setupEventRepo(t)
+
+	insertEvents(t, r,
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "signup", Count: 5, CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "click", Count: 1, CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "click", Count: 1, CreatedAt: testNow.AddDate(0, 0, -1)},
+	)
+
+	counts, err := r.ActionCounts(site, todayQuery(t))
+	assert.Nil(t, err)
+	assert.Equal(t, len(counts), 3)
+	assert.Equal(t, counts[0], ActionCount{Action: "pageview", Events: 3, Total: 3})
+	assert.Equal(t, counts[1], ActionCount{Action: "click", Events: 1, Total: 1})
+	assert.Equal(t, counts[2], ActionCount{Action: "signup", Events: 1, Total: 5})
+}
+
+func TestEventRepoTotals(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	site, r := setupEventRepo(t)
+
+	insertEvents(t, r,
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, VisitorID: visitor("a"), CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "signup", Count: 3, VisitorID: visitor("a"), CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, VisitorID: visitor("b"), CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, VisitorID: visitor("c"), CreatedAt: testNow.AddDate(0, 0, -1)},
+	)
+
+	totals, err := r.Totals(site, todayQuery(t))
+	assert.Nil(t, err)
+	assert.Equal(t, totals, EventTotals{Events: 4, Count: 6, Visitors: 2})
+}
+
+func TestEventRepoVisitStats(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	site, r := setupEventRepo(t)
+
+	insertEvents(t, r,
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, VisitorID: visitor("a"), CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "signup", Count: 1, VisitorID: visitor("a"), CreatedAt: testNow.Add(5 * time.Minute)},
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, VisitorID: visitor("a"), CreatedAt: testNow.Add(5*time.Minute + VisitTimeout + time.Second)},
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, VisitorID: visitor("b"), CreatedAt: testNow.Add(time.Minute)},
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, CreatedAt: testNow},
+	)
+
+	stats, err := r.VisitStats(site, todayQuery(t))
+	assert.Nil(t, err)
+	assert.Equal(t, stats.Visits, 3)
+	assert.Equal(t, stats.Bounces, 2)
+	assert.Equal(t, stats.Pageviews, 3)
+	assert.Equal(t, stats.Duration, 5*time.Minute)
+	assert.Equal(t, len(stats.EntryActions), 1)
+	assert.Equal(t, stats.EntryActions[PageviewAction], 3)
+	assert.Equal(t, len(stats.ExitActions), 2)
+	assert.Equal(t, stats.ExitActions[PageviewAction], 2)
+	assert.Equal(t, stats.ExitActions["signup"], 1)
+}
@@ -0,0 +1,253 @@
+package models
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Funnel is an ordered series of steps visitors are expected to take, e.g.
+// pageview, add_to_cart, checkout and then purchase. Each step has to be
+// reached within Window of the visitor entering the funnel, and on the same
+// UTC day, since that's as long as a visitor keeps their ID.
+type Funnel struct {
+	ID        int64
+	SiteID    int64
+	Name      string
+	Window    time.Duration
+	Steps     FunnelSteps
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// FunnelStep matches events with Action whose properties include every one
+// of Properties. Property values are compared as text, so a step property
+// of "2" matches both "2" and 2.
+type FunnelStep struct {
+	Action     string            `json:"action"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+// String formats the step the way it's entered on the funnel form, e.g.
+// "add_to_cart plan=pro".
+func (s FunnelStep) String() string {
+	parts := []string{s.Action}
+	for _, key := range slices.Sorted(maps.Keys(s.Properties)) {
+		parts = append(parts, key+"="+s.Properties[key])
+	}
+
+	return strings.Join(parts, " ")
+}
+
+type FunnelSteps []FunnelStep
+
+// ParseFunnelSteps reads one step per non-blank line, each an action followed
+// by any number of key=value properties, e.g. "add_to_cart plan=pro".
+func ParseFunnelSteps(text string) (FunnelSteps, error) {
+	var steps FunnelSteps
+	for line := range strings.Lines(text) {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		step := FunnelStep{Action: fields[0]}
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok || key == "" || value == "" {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidFunnelStep, field)
+			}
+
+			if step.Properties == nil {
+				step.Properties = make(map[string]string)
+			}
+			step.Properties[key] = value
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// String formats the steps one per line, the inverse of ParseFunnelSteps.
+func (s FunnelSteps) String() string {
+	lines := make([]string, len(s))
+	for i, step := range s {
+		lines[i] = step.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (s FunnelSteps) Value() (driver.Value, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+func (s *FunnelSteps) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return errors.New("models: funnel steps must be JSON")
+	}
+
+	return json.Unmarshal(b, s)
+}
+
+// FunnelStepCount is how many visitors reached a step of a funnel.
+type FunnelStepCount struct {
+	Step     FunnelStep
+	Visitors int
+
+	// DropOff is the percentage of visitors who reached the previous step but
+	// not this one.
+	DropOff float64
+
+	// Conversion is the percentage of visitors who entered the funnel and
+	// reached this step.
+	Conversion float64
+}
+
+// NewFunnelStepCounts pairs each step with the number of visitors who
+// reached it and works out the percentages between them.
+func NewFunnelStepCounts(steps []FunnelStep, visitors []int) []FunnelStepCount {
+	out := make([]FunnelStepCount, len(steps))
+	for i, step := range steps {
+		out[i] = FunnelStepCount{Step: step, Visitors: visitors[i]}
+		if visitors[0] > 0 {
+			out[i].Conversion = float64(visitors[i]) / float64(visitors[0]) * 100
+		}
+
+		if i > 0 && visitors[i-1] > 0 {
+			out[i].DropOff = float64(visitors[i-1]-visitors[i]) / float64(visitors[i-1]) * 100
+		}
+	}
+
+	return out
+}
+
+type FunnelRepoInterface interface {
+	ListForSite(s *Site) ([]Funnel, error)
+	FindForSite(s *Site, id int64) (Funnel, error)
+	Insert(f *Funnel) error
+	Update(f *Funnel) error
+	Delete(f *Funnel) error
+}
+
+type FunnelRepo struct {
+	db *sql.DB
+}
+
+const funnelColumns = "id, site_id, name, window_seconds, steps, created_at, updated_at"
+
+func scanFunnel(row interface{ Scan(...any) error }, f *Funnel) error {
+	var windowSeconds int64
+	err := row.Scan(&f.ID, &f.SiteID, &f.Name, &windowSeconds, &f.Steps, &f.CreatedAt, &f.UpdatedAt)
+	f.Window = time.Duration(windowSeconds) * time.Second
+	return err
+}
+
+func (r *FunnelRepo) ListForSite(s *Site) ([]Funnel, error) {
+	var funnels []Funnel
+	stmt := "SELECT " + funnelColumns + " FROM funnels WHERE site_id = $1 ORDER BY name;"
+	rows, err := r.db.Query(stmt, s.ID)
+	if err != nil {
+		return funnels, fmt.Errorf("[FunnelRepo.ListForSite] %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var f Funnel
+		err = scanFunnel(rows, &f)
+		if err != nil {
+			return funnels, fmt.Errorf("[FunnelRepo.ListForSite] %w", err)
+		}
+
+		funnels = append(funnels, f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return funnels, fmt.Errorf("[FunnelRepo.ListForSite] %w", err)
+	}
+
+	return funnels, nil
+}
+
+func (r *FunnelRepo) FindForSite(s *Site, id int64) (Funnel, error) {
+	var f Funnel
+	stmt := "SELECT " + funnelColumns + " FROM funnels WHERE site_id = $1 AND id = $2;"
+	err := scanFunnel(r.db.QueryRow(stmt, s.ID, id), &f)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return f, ErrNoRecord
+		}
+
+		return f, fmt.Errorf("[FunnelRepo.FindForSite] %w", err)
+	}
+
+	return f, nil
+}
+
+func (r *FunnelRepo) Insert(f *Funnel) error {
+	stmt := `INSERT INTO funnels (site_id, name, window_seconds, steps)
+	VALUES($1, $2, $3, $4)
+	RETURNING id, created_at, updated_at;`
+
+	err := r.db.QueryRow(stmt, f.SiteID, f.Name, int64(f.Window.Seconds()), f.Steps).Scan(&f.ID, &f.CreatedAt, &f.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("[FunnelRepo.Insert] %w", err)
+	}
+
+	return nil
+}
+
+func (r *FunnelRepo) Update(f *Funnel) error {
+	stmt := `UPDATE funnels SET name = $1, window_seconds = $2, steps = $3, updated_at = (NOW() AT TIME ZONE 'UTC')
+	WHERE id = $4
+	RETURNING updated_at;`
+
+	err := r.db.QueryRow(stmt, f.Name, int64(f.Window.Seconds()), f.Steps, f.ID).Scan(&f.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("[FunnelRepo.Update] %w", err)
+	}
+
+	return nil
+}
+
+func (r *FunnelRepo) Delete(f *Funnel) error {
+	_, err := r.db.Exec("DELETE FROM funnels WHERE id = $1;", f.ID)
+	if err != nil {
+		return fmt.Errorf("[FunnelRepo.Delete] %w", err)
+	}
+
+	return nil
+}
+
+// funnelStepCondition is the SQL condition for events that reach step, read
+// from the events aliased as e. Property keys and values are bound as
+// placeholders after args.
+func funnelStepCondition(step FunnelStep, args []any) (string, []any) {
+	args = append(args, step.Action)
+	conditions := []string{fmt.Sprintf("e.action = $%d", len(args))}
+	for _, key := range slices.Sorted(maps.Keys(step.Properties)) {
+		args = append(args, key, step.Properties[key])
+		conditions = append(conditions, fmt.Sprintf("e.properties ->> $%d = $%d", len(args)-1, len(args)))
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
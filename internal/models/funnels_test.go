@@ -0,0 +1,155 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+)
+
+func TestParseFunnelSteps(t *testing.T) {
+	steps, err := ParseFunnelSteps("pageview\n\n  add_to_cart plan=pro  seats=2\r\npurchase\n")
+	assert.Nil(t, err)
+	assert.Equal(t, len(steps), 3)
+	assert.Equal(t, steps[0].Action, "pageview")
+	assert.Equal(t, len(steps[0].Properties), 0)
+	assert.Equal(t, steps[1].Action, "add_to_cart")
+	assert.Equal(t, steps[1].Properties["plan"], "pro")
+	assert.Equal(t, steps[1].Properties["seats"], "2")
+	assert.Equal(t, steps.String(), "pageview\nadd_to_cart plan=pro seats=2\npurchase")
+
+	for _, text := range []string{"checkout plan", "checkout =pro", "checkout plan="} {
+		_, err := ParseFunnelSteps(text)
+		assert.Equal(t, errors.Is(err, ErrInvalidFunnelStep), true)
+	}
+}
+
+func TestFunnelStepCondition(t *testing.T) {
+	step := FunnelStep{Action: "checkout", Properties: map[string]string{"plan": "pro", "coupon": "SAVE"}}
+	condition, args := funnelStepCondition(step, []any{3600.0})
+	assert.Equal(t, condition, "e.action = $2 AND e.properties ->> $3 = $4 AND e.properties ->> $5 = $6")
+	assert.Equal(t, len(args), 6)
+	assert.Equal(t, args[1], any("checkout"))
+	assert.Equal(t, args[2], any("coupon"))
+	assert.Equal(t, args[5], any("pro"))
+}
+
+func TestNewFunnelStepCounts(t *testing.T) {
+	steps := []FunnelStep{{Action: "pageview"}, {Action: "add_to_cart"}, {Action: "purchase"}}
+	counts := NewFunnelStepCounts(steps, []int{200, 50, 10})
+
+	assert.Equal(t, counts[0].Conversion, 100.0)
+	assert.Equal(t, counts[0].DropOff, 0.0)
+	assert.Equal(t, counts[1].Conversion, 25.0)
+	assert.Equal(t, counts[1].DropOff, 75.0)
+	assert.Equal(t, counts[2].Step.Action, "purchase")
+	assert.Equal(t, counts[2].Conversion, 5.0)
+	assert.Equal(t, counts[2].DropOff, 80.0)
+
+	empty := NewFunnelStepCounts(steps, []int{0, 0, 0})
+	assert.Equal(t, empty[1].DropOff, 0.0)
+}
+
+func TestFunnelRepo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	r := FunnelRepo{db: db}
+	site := setupSite(t, db)
+	otherSite := setupSite(t, db)
+
+	steps, err := ParseFunnelSteps("pageview\nadd_to_cart plan=pro\npurchase")
+	assert.Nil(t, err)
+
+	funnel := &Funnel{SiteID: site.ID, Name: "Checkout", Window: time.Hour, Steps: steps}
+	assert.Nil(t, r.Insert(funnel))
+	assert.Nil(t, r.Insert(&Funnel{SiteID: site.ID, Name: "Activation", Window: time.Hour, Steps: steps[:1]}))
+
+	t.Run("List for site", func(t *testing.T) {
+		funnels, err := r.ListForSite(site)
+		assert.Nil(t, err)
+		assert.Equal(t, len(funnels), 2)
+		assert.Equal(t, funnels[0].Name, "Activation")
+		assert.Equal(t, funnels[1].Name, "Checkout")
+
+		funnels, err = r.ListForSite(otherSite)
+		assert.Nil(t, err)
+		assert.Equal(t, len(funnels), 0)
+	})
+
+	t.Run("Find for site", func(t *testing.T) {
+		found, err := r.FindForSite(site, funnel.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, found.Window, time.Hour)
+		assert.Equal(t, found.Steps.String(), steps.String())
+
+		_, err = r.FindForSite(otherSite, funnel.ID)
+		assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		funnel.Window = 30 * time.Minute
+		funnel.Steps = steps[1:]
+		assert.Nil(t, r.Update(funnel))
+
+		found, err := r.FindForSite(site, funnel.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, found.Window, 30*time.Minute)
+		assert.Equal(t, found.Steps.String(), "add_to_cart plan=pro\npurchase")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.Nil(t, r.Delete(funnel))
+
+		_, err := r.FindForSite(site, funnel.ID)
+		assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+	})
+}
+
+func TestEventRepoFunnelCounts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	site, r := setupEventRepo(t)
+
+	steps, err := ParseFunnelSteps("pageview\nadd_to_cart plan=pro seats=2\npurchase")
+	assert.Nil(t, err)
+	funnel := Funnel{SiteID: site.ID, Name: "Checkout", Window: time.Hour, Steps: steps}
+
+	pro := EventProperties{"plan": "pro", "seats": 2}
+	event := func(visitorID string, action string, properties EventProperties, after time.Duration) *Event {
+		return &Event{SiteID: site.ID, Action: action, Count: 1, Properties: properties, VisitorID: visitor(visitorID), CreatedAt: testNow.Add(after)}
+	}
+	insertEvents(t, r,
+		event("a", "pageview", nil, 0),
+		event("a", "add_to_cart", pro, time.Minute),
+		event("a", "purchase", nil, 2*time.Minute),
+		event("b", "pageview", nil, 0),
+		event("b", "add_to_cart", EventProperties{"plan": "basic", "seats": 2}, time.Minute),
+		event("b", "purchase", nil, 2*time.Minute),
+		event("c", "pageview", nil, 0),
+		event("c", "add_to_cart", pro, 10*time.Minute),
+		event("c", "purchase", nil, 2*time.Hour),
+		event("d", "add_to_cart", pro, 0),
+		event("d", "pageview", nil, time.Minute),
+	)
+
+	counts, err := r.FunnelCounts(site, todayQuery(t), funnel)
+	assert.Nil(t, err)
+	assert.Equal(t, len(counts), 3)
+	assert.Equal(t, counts[0].Visitors, 4)
+	assert.Equal(t, counts[1].Visitors, 2)
+	assert.Equal(t, counts[2].Visitors, 1)
+	assert.Equal(t, counts[2].DropOff, 50.0)
+	assert.Equal(t, counts[2].Conversion, 25.0)
+
+	t.Run("No steps", func(t *testing.T) {
+		counts, err := r.FunnelCounts(site, todayQuery(t), Funnel{})
+		assert.Nil(t, err)
+		assert.Equal(t, len(counts), 0)
+	})
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// GoalDimensions are the dimensions a goal can be defined on.
+var GoalDimensions = []string{DimensionAction, DimensionPath}
+
+// Goal is an event a site wants visitors to reach, e.g. action = signup or
+// path = /checkout/done.
+type Goal struct {
+	ID        int64
+	SiteID    int64
+	Name      string
+	Dimension string
+	Value     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// GoalConversion is how often a goal was completed. Only events with a
+// visitor ID are counted, since conversions are per visitor and visit.
+type GoalConversion struct {
+	Goal     Goal
+	Events   int
+	Visitors int
+	Visits   int
+}
+
+// VisitorRate is the percentage of totalVisitors who completed the goal.
+func (c GoalConversion) VisitorRate(totalVisitors int) float64 {
+	if totalVisitors == 0 {
+		return 0
+	}
+
+	return float64(c.Visitors) / float64(totalVisitors) * 100
+}
+
+// VisitRate is the percentage of totalVisits in which the goal was completed.
+func (c GoalConversion) VisitRate(totalVisits int) float64 {
+	if totalVisits == 0 {
+		return 0
+	}
+
+	return float64(c.Visits) / float64(totalVisits) * 100
+}
+
+type GoalRepoInterface interface {
+	ListForSite(s *Site) ([]Goal, error)
+	FindForSite(s *Site, id int64) (Goal, error)
+	Insert(g *Goal) error
+	Update(g *Goal) error
+	Delete(g *Goal) error
+}
+
+type GoalRepo struct {
+	db *sql.DB
+}
+
+func (r *GoalRepo) ListForSite(s *Site) ([]Goal, error) {
+	var goals []Goal
+	stmt := `
+		SELECT id, site_id, name, dimension, value, created_at, updated_at FROM goals
+		WHERE site_id = $1
+		ORDER BY name;
+	`
+	rows, err := r.db.Query(stmt, s.ID)
+	if err != nil {
+		return goals, fmt.Errorf("[GoalRepo.ListForSite] %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var g Goal
+		err = rows.Scan(&g.ID, &g.SiteID, &g.Name, &g.Dimension, &g.Value, &g.CreatedAt, &g.UpdatedAt)
+		if err != nil {
+			return goals, fmt.Errorf("[GoalRepo.ListForSite] %w", err)
+		}
+
+		goals = append(goals, g)
+	}
+
+	if err = rows.Err(); err != nil {
+		return goals, fmt.Errorf("[GoalRepo.ListForSite] %w", err)
+	}
+
+	return goals, nil
+}
+
+func (r *GoalRepo) FindForSite(s *Site, id int64) (Goal, error) {
+	var g Goal
+	stmt := `
+		SELECT id, site_id, name, dimension, value, created_at, updated_at FROM goals
+		WHERE site_id = $1 AND id = $2;
+	`
+	err := r.db.QueryRow(stmt, s.ID, id).Scan(&g.ID, &g.SiteID, &g.Name, &g.Dimension, &g.Value, &g.CreatedAt, &g.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return g, ErrNoRecord
+		}
+
+		return g, fmt.Errorf("[GoalRepo.FindForSite] %w", err)
+	}
+
+	return g, nil
+}
+
+func (r *GoalRepo) Insert(g *Goal) error {
+	stmt := `INSERT INTO goals (site_id, name, dimension, value)
+	VALUES($1, $2, $3, $4)
+	RETURNING id, created_at, updated_at;`
+
+	err := r.db.QueryRow(stmt, g.SiteID, g.Name, g.Dimension, g.Value).Scan(&g.ID, &g.CreatedAt, &g.UpdatedAt)
+	if err != nil {
+		if err.Error() == `pq: duplicate key value violates unique constraint "idx_goals_site_id_name_unique"` {
+			return ErrDuplicateGoal
+		}
+
+		return fmt.Errorf("[GoalRepo.Insert] %w", err)
+	}
+
+	return nil
+}
+
+func (r *GoalRepo) Update(g *Goal) error {
+	stmt := `UPDATE goals SET name = $1, dimension = $2, value = $3, updated_at = (NOW() AT TIME ZONE 'UTC')
+	WHERE id = $4
+	RETURNING updated_at;`
+
+	err := r.db.QueryRow(stmt, g.Name, g.Dimension, g.Value, g.ID).Scan(&g.UpdatedAt)
+	if err != nil {
+		if err.Error() == `pq: duplicate key value violates unique constraint "idx_goals_site_id_name_unique"` {
+			return ErrDuplicateGoal
+		}
+
+		return fmt.Errorf("[GoalRepo.Update] %w", err)
+	}
+
+	return nil
+}
+
+func (r *GoalRepo) Delete(g *Goal) error {
+	_, err := r.db.Exec("DELETE FROM goals WHERE id = $1;", g.ID)
+	if err != nil {
+		return fmt.Errorf("[GoalRepo.Delete] %w", err)
+	}
+
+	return nil
+}
+
+// goalCondition is the SQL condition for events that complete g, with its
+// value bound as the next placeholder after args.
+func goalCondition(g Goal, args []any) (string, []any) {
+	if !slices.Contains(GoalDimensions, g.Dimension) {
+		return "FALSE", args
+	}
+
+	args = append(args, g.Value)
+	return fmt.Sprintf("%s = $%d", filterColumns[g.Dimension], len(args)), args
+}
+
+// goalArrays splits goals into the parallel arrays GoalConversions unnests.
+func goalArrays(goals []Goal) (any, any, any) {
+	ids := make([]int64, len(goals))
+	dimensions := make([]string, len(goals))
+	values := make([]string, len(goals))
+	for i, g := range goals {
+		ids[i], dimensions[i], values[i] = g.ID, g.Dimension, g.Value
+	}
+
+	return pq.Array(ids), pq.Array(dimensions), pq.Array(values)
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+)
+
+func TestGoalRepo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	r := GoalRepo{db: db}
+	site := setupSite(t, db)
+	otherSite := setupSite(t, db)
+
+	goal := &Goal{SiteID: site.ID, Name: "Signed up", Dimension: DimensionAction, Value: "signup"}
+	assert.Nil(t, r.Insert(goal))
+	assert.Nil(t, r.Insert(&Goal{SiteID: site.ID, Name: "Checked out", Dimension: DimensionPath, Value: "/checkout/done"}))
+	assert.Nil(t, r.Insert(&Goal{SiteID: otherSite.ID, Name: "Signed up", Dimension: DimensionAction, Value: "signup"}))
+
+	t.Run("Duplicate name", func(t *testing.T) {
+		err := r.Insert(&Goal{SiteID: site.ID, Name: "Signed up", Dimension: DimensionAction, Value: "register"})
+		assert.Equal(t, errors.Is(err, ErrDuplicateGoal), true)
+	})
+
+	t.Run("List for site", func(t *testing.T) {
+		goals, err := r.ListForSite(site)
+		assert.Nil(t, err)
+		assert.Equal(t, len(goals), 2)
+		assert.Equal(t, goals[0].Name, "Checked out")
+		assert.Equal(t, goals[1].Name, "Signed up")
+	})
+
+	t.Run("Find for site", func(t *testing.T) {
+		found, err := r.FindForSite(site, goal.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, found.Value, "signup")
+
+		_, err = r.FindForSite(otherSite, goal.ID)
+		assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		goal.Value = "register"
+		assert.Nil(t, r.Update(goal))
+
+		found, err := r.FindForSite(site, goal.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, found.Value, "register")
+
+		goal.Name = "Checked out"
+		err = r.Update(goal)
+		assert.Equal(t, errors.Is(err, ErrDuplicateGoal), true)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.Nil(t, r.Delete(goal))
+
+		_, err := r.FindForSite(site, goal.ID)
+		assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+	})
+}
+
+func TestEventRepoGoalConversions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	site, r := setupEventRepo(t)
+	goalRepo := GoalRepo{db: r.db}
+
+	signup := Goal{SiteID: site.ID, Name: "Signed up", Dimension: DimensionAction, Value: "signup"}
+	done := Goal{SiteID: site.ID, Name: "Checked out", Dimension: DimensionPath, Value: "/checkout/done"}
+	never := Goal{SiteID: site.ID, Name: "Never", Dimension: DimensionAction, Value: "never"}
+	for _, g := range []*Goal{&signup, &done, &never} {
+		assert.Nil(t, goalRepo.Insert(g))
+	}
+
+	insertEvents(t, r,
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, VisitorID: visitor("a"), Path: "/", CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "signup", Count: 1, VisitorID: visitor("a"), CreatedAt: testNow.Add(time.Minute)},
+		&Event{SiteID: site.ID, Action: "signup", Count: 1, VisitorID: visitor("a"), CreatedAt: testNow.Add(2 * time.Minute)},
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, VisitorID: visitor("a"), Path: "/checkout/done", CreatedAt: testNow.Add(3 * time.Minute)},
+		&Event{SiteID: site.ID, Action: "signup", Count: 1, VisitorID: visitor("b"), CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "signup", Count: 1, VisitorID: visitor("b"), CreatedAt: testNow.Add(VisitTimeout + time.Minute)},
+		&Event{SiteID: site.ID, Action: "signup", Count: 1, VisitorID: visitor("c"), CreatedAt: testNow.AddDate(0, 0, -1)},
+	)
+
+	conversions, err := r.GoalConversions(site, todayQuery(t), []Goal{never, signup, done})
+	assert.Nil(t, err)
+	assert.Equal(t, len(conversions), 3)
+	assert.Equal(t, conversions[0], GoalConversion{Goal: never})
+	assert.Equal(t, conversions[1], GoalConversion{Goal: signup, Events: 4, Visitors: 2, Visits: 3})
+	assert.Equal(t, conversions[2], GoalConversion{Goal: done, Events: 1, Visitors: 1, Visits: 1})
+
+	t.Run("No goals", func(t *testing.T) {
+		conversions, err := r.GoalConversions(site, todayQuery(t), nil)
+		assert.Nil(t, err)
+		assert.Equal(t, len(conversions), 0)
+	})
+}
+
+func TestEventRepoGoalFilters(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	site, r := setupEventRepo(t)
+
+	insertEvents(t, r,
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, Path: "/", CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, Path: "/checkout/done", CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "signup", Count: 1, Path: "/", CreatedAt: testNow},
+	)
+
+	q := todayQuery(t)
+	q.Goals = []Goal{
+		{Name: "Signed up", Dimension: DimensionAction, Value: "signup"},
+		{Name: "Checked out", Dimension: DimensionPath, Value: "/checkout/done"},
+	}
+
+	tests := []struct {
+		name    string
+		filters []Filter
+		want    int
+	}{
+		{name: "Goal", filters: []Filter{{Dimension: DimensionGoal, Op: FilterEquals, Value: "Signed up"}}, want: 1},
+		{
+			name: "Either goal",
+			filters: []Filter{
+				{Dimension: DimensionGoal, Op: FilterEquals, Value: "Signed up"},
+				{Dimension: DimensionGoal, Op: FilterEquals, Value: "Checked out"},
+			},
+			want: 2,
+		},
+		{name: "Not a goal", filters: []Filter{{Dimension: DimensionGoal, Op: FilterNotEquals, Value: "Checked out"}}, want: 2},
+		{name: "Unknown goal", filters: []Filter{{Dimension: DimensionGoal, Op: FilterEquals, Value: "Nope"}}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := q
+			q.Filters = tt.filters
+
+			totals, err := r.Totals(site, q)
+			assert.Nil(t, err)
+			assert.Equal(t, totals.Events, tt.want)
+		})
+	}
+}
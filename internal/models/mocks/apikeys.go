@@ -0,0 +1,72 @@
+package mocks
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/models"
+)
+
+type APIKeyRepo struct {
+	Keys map[int64]models.APIKey
+}
+
+func NewAPIKeyRepo() *APIKeyRepo {
+	return &APIKeyRepo{Keys: make(map[int64]models.APIKey)}
+}
+
+func (r *APIKeyRepo) Insert(k *models.APIKey) error {
+	if k.UserID == 0 {
+		return fmt.Errorf("Invalid UserID of 0")
+	}
+
+	k.ID = int64(len(r.Keys) + 1)
+	k.CreatedAt = time.Now().UTC()
+	k.UpdatedAt = k.CreatedAt
+
+	r.Keys[k.ID] = *k
+	return nil
+}
+
+func (r *APIKeyRepo) Touch(k *models.APIKey) error {
+	_, ok := r.Keys[k.ID]
+	if !ok {
+		return fmt.Errorf("No API key with ID %d", k.ID)
+	}
+
+	k.LastUsedAt = sql.NullTime{Valid: true, Time: time.Now().UTC()}
+	r.Keys[k.ID] = *k
+	return nil
+}
+
+func (r *APIKeyRepo) DeleteByID(user models.User, id int64) error {
+	k, ok := r.Keys[id]
+	if !ok || k.UserID != user.ID {
+		return models.ErrNoRecord
+	}
+
+	delete(r.Keys, id)
+	return nil
+}
+
+func (r *APIKeyRepo) FindByToken(token string) (models.APIKey, error) {
+	for _, k := range r.Keys {
+		if k.Token == token {
+			return k, nil
+		}
+	}
+
+	return models.APIKey{}, models.ErrNoRecord
+}
+
+func (r *APIKeyRepo) ListForUser(user *models.User) ([]models.APIKey, error) {
+	var out []models.APIKey
+	for _, k := range r.Keys {
+		if k.UserID == user.ID {
+			out = append(out, k)
+		}
+	}
+
+	return out, nil
+}
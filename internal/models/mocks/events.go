@@ -0,0 +1,168 @@
+package mocks
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/models"
+)
+
+// EventRepo keeps the events inserted into it, and answers the dashboard's
+// queries with the canned results set on it, whatever the query. Query is the
+// last query it was asked, so tests can check what a handler asked for.
+type EventRepo struct {
+	// mu guards Events and Query, which the ingestion workers and requests
+	// write to while tests read from them.
+	mu     sync.Mutex
+	Events map[int64]models.Event
+	Query  models.EventQuery
+
+	DateCounts     map[string]int
+	Metrics        models.EventMetrics
+	EventTotals    models.EventTotals
+	Visits         models.VisitStats
+	Conversions    map[int64]models.GoalConversion
+	FunnelVisitors []int
+	RealtimeStats  models.RealtimeStats
+	Actions        []models.ActionCount
+	Properties     map[string]map[string]int
+}
+
+func NewEventRepo() *EventRepo {
+	return &EventRepo{
+		Events:      make(map[int64]models.Event),
+		Conversions: make(map[int64]models.GoalConversion),
+		Properties:  make(map[string]map[string]int),
+	}
+}
+
+func (r *EventRepo) Insert(e *models.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.insert(e)
+}
+
+func (r *EventRepo) insert(e *models.Event) error {
+	if e.SiteID == 0 {
+		return fmt.Errorf("Invalid SiteID of 0")
+	}
+
+	e.ID = int64(len(r.Events) + 1)
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+	e.UpdatedAt = e.CreatedAt
+
+	r.Events[e.ID] = *e
+	return nil
+}
+
+func (r *EventRepo) InsertMany(events []*models.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Like a single INSERT, either every event is inserted or none are.
+	for _, e := range events {
+		if e.SiteID == 0 {
+			return fmt.Errorf("Invalid SiteID of 0")
+		}
+	}
+
+	for _, e := range events {
+		err := r.insert(e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LastQuery is the query the repo was last asked.
+func (r *EventRepo) LastQuery() models.EventQuery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.Query
+}
+
+func (r *EventRepo) query(q models.EventQuery) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Query = q
+}
+
+func (r *EventRepo) CountsByDate(site *models.Site, q models.EventQuery) (map[string]int, error) {
+	r.query(q)
+
+	out := make(map[string]int)
+	maps.Copy(out, r.DateCounts)
+	return out, nil
+}
+
+func (r *EventRepo) MetricCounts(site *models.Site, q models.EventQuery) (models.EventMetrics, error) {
+	r.query(q)
+	return r.Metrics, nil
+}
+
+func (r *EventRepo) Totals(site *models.Site, q models.EventQuery) (models.EventTotals, error) {
+	r.query(q)
+	return r.EventTotals, nil
+}
+
+func (r *EventRepo) VisitStats(site *models.Site, q models.EventQuery) (models.VisitStats, error) {
+	r.query(q)
+	return r.Visits, nil
+}
+
+func (r *EventRepo) GoalConversions(site *models.Site, q models.EventQuery, goals []models.Goal) ([]models.GoalConversion, error) {
+	r.query(q)
+
+	var out []models.GoalConversion
+	for _, g := range goals {
+		c := r.Conversions[g.ID]
+		c.Goal = g
+		out = append(out, c)
+	}
+
+	return out, nil
+}
+
+func (r *EventRepo) FunnelCounts(site *models.Site, q models.EventQuery, funnel models.Funnel) ([]models.FunnelStepCount, error) {
+	r.query(q)
+
+	if len(funnel.Steps) == 0 {
+		return nil, nil
+	}
+
+	visitors := make([]int, len(funnel.Steps))
+	copy(visitors, r.FunnelVisitors)
+	return models.NewFunnelStepCounts(funnel.Steps, visitors), nil
+}
+
+func (r *EventRepo) Realtime(site *models.Site, since time.Time) (models.RealtimeStats, error) {
+	return r.RealtimeStats, nil
+}
+
+func (r *EventRepo) ActionCounts(site *models.Site, q models.EventQuery) ([]models.ActionCount, error) {
+	r.query(q)
+	return r.Actions, nil
+}
+
+func (r *EventRepo) PropertyKeys(site *models.Site, q models.EventQuery) ([]string, error) {
+	r.query(q)
+	return slices.Sorted(maps.Keys(r.Properties)), nil
+}
+
+func (r *EventRepo) PropertyBreakdown(site *models.Site, q models.EventQuery, key string) (map[string]int, error) {
+	r.query(q)
+
+	out := make(map[string]int)
+	maps.Copy(out, r.Properties[key])
+	return out, nil
+}
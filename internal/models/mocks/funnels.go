@@ -0,0 +1,71 @@
+package mocks
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/models"
+)
+
+type FunnelRepo struct {
+	Funnels map[int64]models.Funnel
+}
+
+func NewFunnelRepo() *FunnelRepo {
+	return &FunnelRepo{Funnels: make(map[int64]models.Funnel)}
+}
+
+func (r *FunnelRepo) ListForSite(s *models.Site) ([]models.Funnel, error) {
+	var out []models.Funnel
+	for _, f := range r.Funnels {
+		if f.SiteID == s.ID {
+			out = append(out, f)
+		}
+	}
+
+	slices.SortFunc(out, func(a, b models.Funnel) int { return cmp.Compare(a.Name, b.Name) })
+	return out, nil
+}
+
+func (r *FunnelRepo) FindForSite(s *models.Site, id int64) (models.Funnel, error) {
+	f, ok := r.Funnels[id]
+	if !ok || f.SiteID != s.ID {
+		return models.Funnel{}, models.ErrNoRecord
+	}
+
+	return f, nil
+}
+
+func (r *FunnelRepo) Insert(f *models.Funnel) error {
+	if f.SiteID == 0 {
+		return fmt.Errorf("Invalid SiteID of 0")
+	}
+
+	f.ID = int64(len(r.Funnels) + 1)
+	f.CreatedAt = time.Now().UTC()
+	f.UpdatedAt = f.CreatedAt
+
+	r.Funnels[f.ID] = *f
+	return nil
+}
+
+func (r *FunnelRepo) Update(f *models.Funnel) error {
+	if _, ok := r.Funnels[f.ID]; !ok {
+		return fmt.Errorf("No funnel with ID %d", f.ID)
+	}
+
+	f.UpdatedAt = time.Now().UTC()
+	r.Funnels[f.ID] = *f
+	return nil
+}
+
+func (r *FunnelRepo) Delete(f *models.Funnel) error {
+	if _, ok := r.Funnels[f.ID]; !ok {
+		return fmt.Errorf("No funnel with ID %d", f.ID)
+	}
+
+	delete(r.Funnels, f.ID)
+	return nil
+}
@@ -0,0 +1,89 @@
+package mocks
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/models"
+)
+
+type GoalRepo struct {
+	Goals map[int64]models.Goal
+}
+
+func NewGoalRepo() *GoalRepo {
+	return &GoalRepo{Goals: make(map[int64]models.Goal)}
+}
+
+func (r *GoalRepo) ListForSite(s *models.Site) ([]models.Goal, error) {
+	var out []models.Goal
+	for _, g := range r.Goals {
+		if g.SiteID == s.ID {
+			out = append(out, g)
+		}
+	}
+
+	slices.SortFunc(out, func(a, b models.Goal) int { return cmp.Compare(a.Name, b.Name) })
+	return out, nil
+}
+
+func (r *GoalRepo) FindForSite(s *models.Site, id int64) (models.Goal, error) {
+	g, ok := r.Goals[id]
+	if !ok || g.SiteID != s.ID {
+		return models.Goal{}, models.ErrNoRecord
+	}
+
+	return g, nil
+}
+
+func (r *GoalRepo) Insert(g *models.Goal) error {
+	if g.SiteID == 0 {
+		return fmt.Errorf("Invalid SiteID of 0")
+	}
+
+	if r.nameTaken(g) {
+		return models.ErrDuplicateGoal
+	}
+
+	g.ID = int64(len(r.Goals) + 1)
+	g.CreatedAt = time.Now().UTC()
+	g.UpdatedAt = g.CreatedAt
+
+	r.Goals[g.ID] = *g
+	return nil
+}
+
+func (r *GoalRepo) Update(g *models.Goal) error {
+	if _, ok := r.Goals[g.ID]; !ok {
+		return fmt.Errorf("No goal with ID %d", g.ID)
+	}
+
+	if r.nameTaken(g) {
+		return models.ErrDuplicateGoal
+	}
+
+	g.UpdatedAt = time.Now().UTC()
+	r.Goals[g.ID] = *g
+	return nil
+}
+
+func (r *GoalRepo) Delete(g *models.Goal) error {
+	if _, ok := r.Goals[g.ID]; !ok {
+		return fmt.Errorf("No goal with ID %d", g.ID)
+	}
+
+	delete(r.Goals, g.ID)
+	return nil
+}
+
+func (r *GoalRepo) nameTaken(g *models.Goal) bool {
+	for _, other := range r.Goals {
+		if other.ID != g.ID && other.SiteID == g.SiteID && other.Name == g.Name {
+			return true
+		}
+	}
+
+	return false
+}
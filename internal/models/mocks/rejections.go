@@ -0,0 +1,41 @@
+package mocks
+
+import (
+	"sync"
+
+	"github.com/robyparr/event-horizon/internal/models"
+)
+
+type RejectionRepo struct {
+	mu     sync.Mutex
+	Counts map[models.RejectionKey]int
+}
+
+func NewRejectionRepo() *RejectionRepo {
+	return &RejectionRepo{Counts: make(map[models.RejectionKey]int)}
+}
+
+func (r *RejectionRepo) AddCounts(counts map[models.RejectionKey]int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, count := range counts {
+		r.Counts[key] += count
+	}
+
+	return nil
+}
+
+func (r *RejectionRepo) CountsForSite(site *models.Site, dr models.DateRange) (map[string]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := make(map[string]int)
+	for key, count := range r.Counts {
+		if key.SiteToken == site.Token && key.Day >= dr.StartDate() && key.Day <= dr.EndDate() {
+			counts[key.Reason] += count
+		}
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,36 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/utils"
+)
+
+type VisitorSaltRepo struct {
+	Salts map[string]string
+}
+
+func NewVisitorSaltRepo() *VisitorSaltRepo {
+	return &VisitorSaltRepo{Salts: make(map[string]string)}
+}
+
+func (r *VisitorSaltRepo) FindOrCreate(day time.Time) (string, error) {
+	date := day.UTC().Format(time.DateOnly)
+	if _, ok := r.Salts[date]; !ok {
+		r.Salts[date] = utils.Token()
+	}
+
+	return r.Salts[date], nil
+}
+
+func (r *VisitorSaltRepo) DeleteBefore(day time.Time) (int64, error) {
+	var count int64
+	for date := range r.Salts {
+		if date < day.UTC().Format(time.DateOnly) {
+			delete(r.Salts, date)
+			count += 1
+		}
+	}
+
+	return count, nil
+}
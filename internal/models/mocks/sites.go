@@ -41,7 +41,7 @@ func (r *SiteRepo) Insert(s *models.Site) error {
 
 func (r *SiteRepo) FindForUser(u *models.User, id int64) (models.Site, error) {
 	for _, s := range r.Sites {
-		if s.ID == id {
+		if s.ID == id && s.UserID == u.ID {
 			return s, nil
 		}
 	}
@@ -68,3 +68,27 @@ func (r *SiteRepo) FindByToken(token string) (models.Site, error) {
 
 	return models.Site{}, models.ErrNoRecord
 }
+
+func (r *SiteRepo) AnyAllowsHostname(hostname string) (bool, error) {
+	for _, site := range r.Sites {
+		if site.AllowsHostname(hostname) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *SiteRepo) UpdateSettings(s *models.Site) error {
+	site, ok := r.Sites[s.ID]
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	s.UpdatedAt = time.Now().UTC()
+	site.AllowedHostnames = s.AllowedHostnames
+	site.ExcludedIPs = s.ExcludedIPs
+	site.UpdatedAt = s.UpdatedAt
+	r.Sites[s.ID] = site
+	return nil
+}
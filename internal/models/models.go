@@ -5,17 +5,27 @@ import (
 )
 
 type Repos struct {
-	Users    UserRepoInterface
-	Sessions SessionRepoInterface
-	Sites    SiteRepoInterface
-	Events   EventRepoInterface
+	Users      UserRepoInterface
+	Sessions   SessionRepoInterface
+	Sites      SiteRepoInterface
+	Events     EventRepoInterface
+	APIKeys    APIKeyRepoInterface
+	Salts      VisitorSaltRepoInterface
+	Goals      GoalRepoInterface
+	Funnels    FunnelRepoInterface
+	Rejections RejectionRepoInterface
 }
 
 func NewRepos(db *sql.DB) *Repos {
 	return &Repos{
-		Users:    &UserRepo{db: db},
-		Sessions: &SessionRepo{db: db},
-		Sites:    &SiteRepo{db: db},
-		Events:   &EventRepo{db: db},
+		Users:      &UserRepo{db: db},
+		Sessions:   &SessionRepo{db: db},
+		Sites:      &SiteRepo{db: db},
+		Events:     &EventRepo{db: db},
+		APIKeys:    &APIKeyRepo{db: db},
+		Salts:      &VisitorSaltRepo{db: db},
+		Goals:      &GoalRepo{db: db},
+		Funnels:    &FunnelRepo{db: db},
+		Rejections: &RejectionRepo{db: db},
 	}
 }
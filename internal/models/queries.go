@@ -0,0 +1,215 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const (
+	DimensionAction      = "action"
+	DimensionBrowser     = "browser"
+	DimensionOS          = "os"
+	DimensionDeviceType  = "device_type"
+	DimensionReferrer    = "referrer"
+	DimensionChannel     = "channel"
+	DimensionHostname    = "hostname"
+	DimensionPath        = "path"
+	DimensionUTMSource   = "utm_source"
+	DimensionUTMMedium   = "utm_medium"
+	DimensionUTMCampaign = "utm_campaign"
+	DimensionCountry     = "country"
+	DimensionRegion      = "region"
+	DimensionCity        = "city"
+
+	// DimensionGoal filters on one of the site's goals by name. It can't be
+	// broken down by, so it isn't one of the FilterDimensions.
+	DimensionGoal = "goal"
+)
+
+const (
+	FilterEquals    = "eq"
+	FilterNotEquals = "neq"
+	FilterContains  = "contains"
+)
+
+var FilterDimensions = []string{
+	DimensionAction,
+	DimensionBrowser,
+	DimensionOS,
+	DimensionDeviceType,
+	DimensionReferrer,
+	DimensionChannel,
+	DimensionHostname,
+	DimensionPath,
+	DimensionUTMSource,
+	DimensionUTMMedium,
+	DimensionUTMCampaign,
+	DimensionCountry,
+	DimensionRegion,
+	DimensionCity,
+}
+
+var FilterOps = []string{FilterEquals, FilterNotEquals, FilterContains}
+
+// filterColumns maps each dimension that can be filtered on to the SQL
+// expression it's compared against. Only these expressions are ever
+// interpolated into a query; filter values are always bound as parameters.
+// Referrers are reported by source name when they're a known source, and
+// events without a referrer as "direct", so they're filtered that way too.
+var filterColumns = map[string]string{
+	DimensionAction:      "action",
+	DimensionBrowser:     "browser",
+	DimensionOS:          "os",
+	DimensionDeviceType:  "device_type",
+	DimensionReferrer:    "COALESCE(NULLIF(referrer_source, ''), referrer, 'direct')",
+	DimensionChannel:     "channel",
+	DimensionHostname:    "hostname",
+	DimensionPath:        "path",
+	DimensionUTMSource:   "utm_source",
+	DimensionUTMMedium:   "utm_medium",
+	DimensionUTMCampaign: "utm_campaign",
+	DimensionCountry:     "country",
+	DimensionRegion:      "region",
+	DimensionCity:        "city",
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Filter limits events to those where Dimension is equal to, not equal to or
+// contains Value.
+type Filter struct {
+	Dimension string
+	Op        string
+	Value     string
+}
+
+// ParseFilter reads a filter in the "dimension:op:value" form used in
+// dashboard URLs, e.g. "browser:eq:Firefox".
+func ParseFilter(s string) (Filter, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) != 3 {
+		return Filter{}, ErrInvalidFilter
+	}
+
+	return NewFilter(parts[0], parts[1], parts[2])
+}
+
+func NewFilter(dimension string, op string, value string) (Filter, error) {
+	f := Filter{Dimension: dimension, Op: op, Value: value}
+	_, ok := filterColumns[dimension]
+	if dimension == DimensionGoal {
+		ok = op != FilterContains
+	}
+
+	if !ok || !slices.Contains(FilterOps, op) || value == "" {
+		return Filter{}, ErrInvalidFilter
+	}
+
+	return f, nil
+}
+
+func (f Filter) String() string {
+	return f.Dimension + ":" + f.Op + ":" + f.Value
+}
+
+// EventQuery narrows down the events that dashboard queries aggregate. Goals
+// are the site's goals, which goal filters are looked up in.
+type EventQuery struct {
+	Range   DateRange
+	Filters []Filter
+	Goals   []Goal
+}
+
+// WithRange returns a copy of the query over a different date range, e.g. for
+// comparing against an earlier period.
+func (q EventQuery) WithRange(dr DateRange) EventQuery {
+	q.Range = dr
+	return q
+}
+
+// WithFilter returns a copy of the query with f added, unless it's already
+// applied.
+func (q EventQuery) WithFilter(f Filter) EventQuery {
+	if !slices.Contains(q.Filters, f) {
+		q.Filters = append(slices.Clone(q.Filters), f)
+	}
+
+	return q
+}
+
+// Goal returns the query's goal with the given name.
+func (q EventQuery) Goal(name string) (Goal, bool) {
+	i := slices.IndexFunc(q.Goals, func(g Goal) bool { return g.Name == name })
+	if i < 0 {
+		return Goal{}, false
+	}
+
+	return q.Goals[i], true
+}
+
+// whereClause builds the conditions shared by every dashboard query.
+// Placeholders are numbered after the args the query already uses, and the
+// returned args include the filter's own values.
+//
+// Equality filters on the same dimension match any of their values, e.g.
+// action is pageview or signup. Every other filter must match. Goal filters
+// match the events that complete the goal, and nothing if it doesn't exist.
+func (q EventQuery) whereClause(site *Site, args []any) (string, []any) {
+	var conditions []string
+	add := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	add("site_id = $%d", site.ID)
+	add("created_at >= $%d", q.Range.StartsAt())
+	add("created_at < $%d", q.Range.EndsBefore())
+
+	var eqDimensions, eqGoals []string
+	eqValues := make(map[string][]string)
+	for _, f := range q.Filters {
+		if f.Dimension == DimensionGoal {
+			goal, _ := q.Goal(f.Value)
+
+			var condition string
+			condition, args = goalCondition(goal, args)
+			if f.Op == FilterEquals {
+				eqGoals = append(eqGoals, condition)
+			} else {
+				conditions = append(conditions, "NOT ("+condition+")")
+			}
+
+			continue
+		}
+
+		column, ok := filterColumns[f.Dimension]
+		if !ok {
+			continue
+		}
+
+		switch f.Op {
+		case FilterEquals:
+			if _, ok := eqValues[f.Dimension]; !ok {
+				eqDimensions = append(eqDimensions, f.Dimension)
+			}
+			eqValues[f.Dimension] = append(eqValues[f.Dimension], f.Value)
+		case FilterNotEquals:
+			add(column+" <> $%d", f.Value)
+		case FilterContains:
+			add(column+" ILIKE $%d", "%"+likeEscaper.Replace(f.Value)+"%")
+		}
+	}
+
+	for _, dimension := range eqDimensions {
+		add(filterColumns[dimension]+" = ANY($%d)", pq.Array(eqValues[dimension]))
+	}
+
+	if len(eqGoals) > 0 {
+		conditions = append(conditions, "("+strings.Join(eqGoals, " OR ")+")")
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
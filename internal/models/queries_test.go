@@ -0,0 +1,137 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Filter
+		wantErr error
+	}{
+		{value: "browser:eq:Firefox", want: Filter{Dimension: DimensionBrowser, Op: FilterEquals, Value: "Firefox"}},
+		{value: "referrer:contains:https://example.com", want: Filter{Dimension: DimensionReferrer, Op: FilterContains, Value: "https://example.com"}},
+		{value: "device_type:neq:Desktop", want: Filter{Dimension: DimensionDeviceType, Op: FilterNotEquals, Value: "Desktop"}},
+		{value: "browser:eq", wantErr: ErrInvalidFilter},
+		{value: "browser:eq:", wantErr: ErrInvalidFilter},
+		{value: "site_id:eq:1", wantErr: ErrInvalidFilter},
+		{value: "browser:gt:Firefox", wantErr: ErrInvalidFilter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			f, err := ParseFilter(tt.value)
+			assert.Equal(t, err, tt.wantErr)
+			assert.Equal(t, f, tt.want)
+
+			if err == nil {
+				assert.Equal(t, f.String(), tt.value)
+			}
+		})
+	}
+}
+
+func TestEventQueryWhereClause(t *testing.T) {
+	site := &Site{ID: 1}
+	q := EventQuery{Filters: []Filter{
+		{Dimension: DimensionAction, Op: FilterEquals, Value: "pageview"},
+		{Dimension: DimensionBrowser, Op: FilterContains, Value: "50%_off"},
+		{Dimension: DimensionAction, Op: FilterEquals, Value: "signup"},
+		{Dimension: DimensionReferrer, Op: FilterNotEquals, Value: "direct"},
+		{Dimension: "id; DROP TABLE events", Op: FilterEquals, Value: "1"},
+	}}
+
+	where, args := q.whereClause(site, []any{"day"})
+	assert.Equal(t, where, "site_id = $2 AND created_at >= $3 AND created_at < $4"+
+		" AND browser ILIKE $5 AND COALESCE(NULLIF(referrer_source, ''), referrer, 'direct') <> $6 AND action = ANY($7)")
+	assert.Equal(t, len(args), 7)
+	assert.Equal(t, args[4], any(`%50\%\_off%`))
+	assert.Equal(t, args[5], any("direct"))
+}
+
+func TestEventQueryGoalWhereClause(t *testing.T) {
+	site := &Site{ID: 1}
+	q := EventQuery{
+		Goals: []Goal{
+			{Name: "Signup", Dimension: DimensionAction, Value: "signup"},
+			{Name: "Checkout", Dimension: DimensionPath, Value: "/checkout/done"},
+		},
+		Filters: []Filter{
+			{Dimension: DimensionGoal, Op: FilterEquals, Value: "Signup"},
+			{Dimension: DimensionGoal, Op: FilterEquals, Value: "Checkout"},
+			{Dimension: DimensionGoal, Op: FilterNotEquals, Value: "Missing"},
+		},
+	}
+
+	where, args := q.whereClause(site, nil)
+	assert.Equal(t, where, "site_id = $1 AND created_at >= $2 AND created_at < $3"+
+		" AND NOT (FALSE) AND (action = $4 OR path = $5)")
+	assert.Equal(t, len(args), 5)
+	assert.Equal(t, args[3], any("signup"))
+	assert.Equal(t, args[4], any("/checkout/done"))
+
+	_, err := NewFilter(DimensionGoal, FilterContains, "Sign")
+	assert.Equal(t, err, ErrInvalidFilter)
+}
+
+func TestEventRepoFilters(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	site, r := setupEventRepo(t)
+
+	news := sql.NullString{String: "news.example.com", Valid: true}
+	google := sql.NullString{String: "https://www.google.com/", Valid: true}
+	insertEvents(t, r,
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, Browser: "Firefox", CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "pageview", Count: 1, Browser: "Chrome", Referrer: google, ReferrerSource: "Google", CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "signup", Count: 1, Browser: "Firefox", Referrer: news, CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: "click", Count: 1, Browser: "Safari", CreatedAt: testNow},
+	)
+
+	tests := []struct {
+		name    string
+		filters []Filter
+		want    int
+	}{
+		{name: "No filters", want: 4},
+		{
+			name: "Either action",
+			filters: []Filter{
+				{Dimension: DimensionAction, Op: FilterEquals, Value: "pageview"},
+				{Dimension: DimensionAction, Op: FilterEquals, Value: "signup"},
+			},
+			want: 3,
+		},
+		{
+			name: "Action and browser",
+			filters: []Filter{
+				{Dimension: DimensionAction, Op: FilterEquals, Value: "pageview"},
+				{Dimension: DimensionBrowser, Op: FilterEquals, Value: "Firefox"},
+			},
+			want: 1,
+		},
+		{name: "Not equal", filters: []Filter{{Dimension: DimensionBrowser, Op: FilterNotEquals, Value: "Firefox"}}, want: 2},
+		{name: "Contains", filters: []Filter{{Dimension: DimensionBrowser, Op: FilterContains, Value: "FIRE"}}, want: 2},
+		{name: "Contains a wildcard", filters: []Filter{{Dimension: DimensionBrowser, Op: FilterContains, Value: "%"}}, want: 0},
+		{name: "Direct", filters: []Filter{{Dimension: DimensionReferrer, Op: FilterEquals, Value: "direct"}}, want: 2},
+		{name: "Referrer source", filters: []Filter{{Dimension: DimensionReferrer, Op: FilterEquals, Value: "Google"}}, want: 1},
+		{name: "Referrer", filters: []Filter{{Dimension: DimensionReferrer, Op: FilterEquals, Value: "news.example.com"}}, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := todayQuery(t)
+			q.Filters = tt.filters
+
+			totals, err := r.Totals(site, q)
+			assert.Nil(t, err)
+			assert.Equal(t, totals.Events, tt.want)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// RealtimeWindow is how recently a visitor must have been seen to count as
+// currently on a site.
+const RealtimeWindow = 5 * time.Minute
+
+const realtimeTopCount = 5
+
+// RealtimeStats summarizes a site's events since a point in time.
+type RealtimeStats struct {
+	Visitors  int             `json:"visitors"`
+	Actions   []RealtimeCount `json:"actions"`
+	Referrers []RealtimeCount `json:"referrers"`
+}
+
+// RealtimeCount is the number of events for an action, or the number of
+// visitors a referrer sent.
+type RealtimeCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Realtime counts the visitors seen since since along with the top actions
+// and the top referrers that brought them.
+func (r *EventRepo) Realtime(site *Site, since time.Time) (RealtimeStats, error) {
+	stats := RealtimeStats{Actions: []RealtimeCount{}, Referrers: []RealtimeCount{}}
+	stmt := fmt.Sprintf(`
+		WITH recent AS (
+			SELECT * FROM events WHERE site_id = $1 AND created_at >= $2
+		)
+		SELECT 'visitors', '', COUNT(DISTINCT visitor_id) FROM recent
+		UNION ALL
+		(
+			SELECT 'action', action, COUNT(*) FROM recent
+			GROUP BY action
+			ORDER BY 3 DESC, 2
+			LIMIT $3
+		)
+		UNION ALL
+		(
+			SELECT 'referrer', %s, COUNT(DISTINCT visitor_id) FROM recent
+			WHERE referrer IS NOT NULL
+			GROUP BY 2
+			ORDER BY 3 DESC, 2
+			LIMIT $3
+		);
+	`, filterColumns[DimensionReferrer])
+
+	rows, err := r.db.Query(stmt, site.ID, since.UTC(), realtimeTopCount)
+	if err != nil {
+		return stats, fmt.Errorf("[EventRepo.Realtime] %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var kind string
+		var c RealtimeCount
+		err := rows.Scan(&kind, &c.Name, &c.Count)
+		if err != nil {
+			return stats, fmt.Errorf("[EventRepo.Realtime] %w", err)
+		}
+
+		switch kind {
+		case "visitors":
+			stats.Visitors = c.Count
+		case "action":
+			stats.Actions = append(stats.Actions, c)
+		case "referrer":
+			stats.Referrers = append(stats.Referrers, c)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return stats, fmt.Errorf("[EventRepo.Realtime] %w", err)
+	}
+
+	return stats, nil
+}
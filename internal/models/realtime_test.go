@@ -0,0 +1,47 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+)
+
+func TestEventRepoRealtime(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	site, r := setupEventRepo(t)
+	otherSite := setupSite(t, r.db)
+
+	google := sql.NullString{String: "https://www.google.com/", Valid: true}
+	news := sql.NullString{String: "news.example.com", Valid: true}
+	insertEvents(t, r,
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, VisitorID: visitor("a"), Referrer: google, ReferrerSource: "Google", CreatedAt: testNow.Add(-time.Minute)},
+		&Event{SiteID: site.ID, Action: "click", Count: 1, VisitorID: visitor("a"), Referrer: google, ReferrerSource: "Google", CreatedAt: testNow},
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, VisitorID: visitor("b"), Referrer: news, CreatedAt: testNow.Add(-2 * time.Minute)},
+		&Event{SiteID: site.ID, Action: PageviewAction, Count: 1, VisitorID: visitor("b"), CreatedAt: testNow.Add(-time.Minute)},
+		&Event{SiteID: site.ID, Action: "signup", Count: 1, VisitorID: visitor("c"), Referrer: news, CreatedAt: testNow.Add(-RealtimeWindow - time.Second)},
+		&Event{SiteID: otherSite.ID, Action: "signup", Count: 1, VisitorID: visitor("d"), CreatedAt: testNow},
+	)
+
+	stats, err := r.Realtime(site, testNow.Add(-RealtimeWindow))
+	assert.Nil(t, err)
+	assert.Equal(t, stats.Visitors, 2)
+	assert.Equal(t, len(stats.Actions), 2)
+	assert.Equal(t, stats.Actions[0], RealtimeCount{Name: PageviewAction, Count: 3})
+	assert.Equal(t, stats.Actions[1], RealtimeCount{Name: "click", Count: 1})
+	assert.Equal(t, len(stats.Referrers), 2)
+	assert.Equal(t, stats.Referrers[0], RealtimeCount{Name: "Google", Count: 1})
+	assert.Equal(t, stats.Referrers[1], RealtimeCount{Name: "news.example.com", Count: 1})
+
+	t.Run("Nothing recent", func(t *testing.T) {
+		stats, err := r.Realtime(site, testNow.Add(time.Minute))
+		assert.Nil(t, err)
+		assert.Equal(t, stats.Visitors, 0)
+		assert.Equal(t, len(stats.Actions), 0)
+		assert.Equal(t, len(stats.Referrers), 0)
+	})
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// RejectionKey identifies a count of the events API requests that were turned
+// away for one reason, like being over a rate limit, on one UTC day. Requests
+// are counted by the token they were sent with, since some are turned away
+// before their site is looked up.
+type RejectionKey struct {
+	SiteToken string
+	Day       string
+	Reason    string
+}
+
+type RejectionRepoInterface interface {
+	AddCounts(counts map[RejectionKey]int) error
+	CountsForSite(site *Site, dr DateRange) (map[string]int, error)
+}
+
+type RejectionRepo struct {
+	db *sql.DB
+}
+
+// AddCounts adds counts to the totals already saved. Counts for tokens that
+// don't belong to a site are ignored.
+func (r *RejectionRepo) AddCounts(counts map[RejectionKey]int) error {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	var tokens, days, reasons []string
+	var totals []int64
+	for key, count := range counts {
+		tokens = append(tokens, key.SiteToken)
+		days = append(days, key.Day)
+		reasons = append(reasons, key.Reason)
+		totals = append(totals, int64(count))
+	}
+
+	stmt := `
+		INSERT INTO rejected_requests (site_id, day, reason, count)
+		SELECT sites.id, r.day, r.reason, r.count
+		FROM UNNEST($1::TEXT[], $2::DATE[], $3::TEXT[], $4::BIGINT[]) AS r(token, day, reason, count)
+		JOIN sites ON sites.token = r.token
+		ON CONFLICT (site_id, day, reason) DO UPDATE
+			SET count = rejected_requests.count + EXCLUDED.count;`
+
+	_, err := r.db.Exec(stmt, pq.Array(tokens), pq.Array(days), pq.Array(reasons), pq.Array(totals))
+	if err != nil {
+		return fmt.Errorf("[RejectionRepo.AddCounts] %w", err)
+	}
+
+	return nil
+}
+
+// CountsForSite totals site's rejected requests by reason over the UTC days
+// that dr's dates fall on.
+func (r *RejectionRepo) CountsForSite(site *Site, dr DateRange) (map[string]int, error) {
+	stmt := `
+		SELECT reason, SUM(count) FROM rejected_requests
+		WHERE site_id = $1 AND day BETWEEN $2 AND $3
+		GROUP BY reason;`
+
+	rows, err := r.db.Query(stmt, site.ID, dr.StartDate(), dr.EndDate())
+	if err != nil {
+		return nil, fmt.Errorf("[RejectionRepo.CountsForSite] %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var reason string
+		var count int
+		err := rows.Scan(&reason, &count)
+		if err != nil {
+			return nil, fmt.Errorf("[RejectionRepo.CountsForSite] %w", err)
+		}
+
+		counts[reason] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[RejectionRepo.CountsForSite] %w", err)
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+)
+
+func TestRejectionRepo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	r := RejectionRepo{db: db}
+	site := setupSite(t, db)
+	otherSite := setupSite(t, db)
+
+	assert.Nil(t, r.AddCounts(map[RejectionKey]int{
+		{SiteToken: site.Token, Day: "2026-10-17", Reason: "rate_limit_ip"}:      2,
+		{SiteToken: site.Token, Day: "2026-10-18", Reason: "rate_limit_ip"}:      1,
+		{SiteToken: site.Token, Day: "2026-10-18", Reason: "user_agent"}:         4,
+		{SiteToken: otherSite.Token, Day: "2026-10-18", Reason: "user_agent"}:    8,
+		{SiteToken: "no-such-token", Day: "2026-10-18", Reason: "rate_limit_ip"}: 16,
+	}))
+	assert.Nil(t, r.AddCounts(map[RejectionKey]int{
+		{SiteToken: site.Token, Day: "2026-10-18", Reason: "rate_limit_ip"}: 1,
+	}))
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		preset string
+		want   map[string]int
+	}{
+		{name: "Today", preset: RangeToday, want: map[string]int{"rate_limit_ip": 2, "user_agent": 4}},
+		{name: "Last 7 days", preset: Range7Days, want: map[string]int{"rate_limit_ip": 4, "user_agent": 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dr, err := NewDateRange(tt.preset, now, time.UTC)
+			assert.Nil(t, err)
+
+			counts, err := r.CountsForSite(site, dr)
+			assert.Nil(t, err)
+			assert.Equal(t, len(counts), len(tt.want))
+			for reason, count := range tt.want {
+				assert.Equal(t, counts[reason], count)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/utils"
+)
+
+type VisitorSaltRepoInterface interface {
+	FindOrCreate(day time.Time) (string, error)
+	DeleteBefore(day time.Time) (int64, error)
+}
+
+// VisitorSaltRepo stores the salts that visitor IDs are hashed with. There's
+// one salt per UTC day and old ones are deleted once the day is over, so
+// visitor IDs can't be linked across days or recomputed afterwards.
+type VisitorSaltRepo struct {
+	db *sql.DB
+}
+
+// FindOrCreate returns the salt for day, creating it if it doesn't exist
+// yet. Every app instance that asks for the same day gets the same salt.
+func (r *VisitorSaltRepo) FindOrCreate(day time.Time) (string, error) {
+	stmt := `
+		INSERT INTO visitor_salts (day, salt) VALUES ($1, $2)
+		ON CONFLICT (day) DO NOTHING;`
+
+	date := day.UTC().Format(dateFormat)
+	_, err := r.db.Exec(stmt, date, utils.Token()+utils.Token())
+	if err != nil {
+		return "", fmt.Errorf("[VisitorSaltRepo.FindOrCreate] %w", err)
+	}
+
+	var salt string
+	err = r.db.QueryRow("SELECT salt FROM visitor_salts WHERE day = $1;", date).Scan(&salt)
+	if err != nil {
+		return "", fmt.Errorf("[VisitorSaltRepo.FindOrCreate] %w", err)
+	}
+
+	return salt, nil
+}
+
+func (r *VisitorSaltRepo) DeleteBefore(day time.Time) (int64, error) {
+	result, err := r.db.Exec("DELETE FROM visitor_salts WHERE day < $1;", day.UTC().Format(dateFormat))
+	if err != nil {
+		return 0, fmt.Errorf("[VisitorSaltRepo.DeleteBefore] %w", err)
+	}
+
+	deletedRecords, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("[VisitorSaltRepo.DeleteBefore] %w", err)
+	}
+
+	return deletedRecords, nil
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+)
+
+func TestVisitorSaltRepo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	r := VisitorSaltRepo{db: db}
+
+	yesterday := time.Date(2026, 10, 17, 23, 0, 0, 0, time.UTC)
+	today := time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC)
+
+	salt, err := r.FindOrCreate(today)
+	assert.Nil(t, err)
+	assert.NotEqual(t, salt, "")
+
+	t.Run("Same day", func(t *testing.T) {
+		again, err := r.FindOrCreate(today.Add(12 * time.Hour))
+		assert.Nil(t, err)
+		assert.Equal(t, again, salt)
+	})
+
+	t.Run("Another day", func(t *testing.T) {
+		other, err := r.FindOrCreate(yesterday)
+		assert.Nil(t, err)
+		assert.NotEqual(t, other, salt)
+	})
+
+	t.Run("Delete before", func(t *testing.T) {
+		old, err := r.FindOrCreate(yesterday)
+		assert.Nil(t, err)
+
+		deleted, err := r.DeleteBefore(today)
+		assert.Nil(t, err)
+		assert.Equal(t, deleted, 1)
+
+		replaced, err := r.FindOrCreate(yesterday)
+		assert.Nil(t, err)
+		assert.NotEqual(t, replaced, old)
+
+		again, err := r.FindOrCreate(today)
+		assert.Nil(t, err)
+		assert.Equal(t, again, salt)
+	})
+}
@@ -3,28 +3,182 @@ package models
 import (
 	"database/sql"
 	"fmt"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/robyparr/event-horizon/internal/utils"
 )
 
+const siteColumns = "id, user_id, name, token, allowed_hostnames, excluded_ips, created_at, updated_at"
+
 type Site struct {
-	ID        int64
-	UserID    int64
-	Name      string
-	Token     string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID     int64
+	UserID int64
+	Name   string
+	Token  string
+	// AllowedHostnames limits which pages may send events for the site. Each
+	// is a hostname or a "*.example.com" wildcard for its subdomains. An
+	// empty list allows any hostname.
+	AllowedHostnames []string
+	// ExcludedIPs are IP addresses and CIDR ranges, like the site owner's
+	// office, whose events aren't recorded.
+	ExcludedIPs []string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
 
 	EventCount int
 }
 
+// AllowsHostname reports whether events from pages on hostname are accepted.
+func (s Site) AllowsHostname(hostname string) bool {
+	if len(s.AllowedHostnames) == 0 {
+		return true
+	}
+
+	hostname = strings.TrimSuffix(strings.ToLower(hostname), ".")
+	for _, allowed := range s.AllowedHostnames {
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok {
+			if strings.HasSuffix(hostname, suffix) {
+				return true
+			}
+		} else if hostname == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseAllowedHostnames reads one hostname per non-blank line. Hostnames are
+// lowercased and may be pasted as URLs, in which case only the hostname is
+// kept. A leading "*." allows every subdomain.
+func ParseAllowedHostnames(text string) ([]string, error) {
+	var hostnames []string
+	for line := range strings.Lines(text) {
+		hostname := strings.ToLower(strings.TrimSpace(line))
+		if hostname == "" {
+			continue
+		}
+
+		if strings.Contains(hostname, "://") {
+			u, err := url.Parse(hostname)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidHostname, hostname)
+			}
+			hostname = u.Hostname()
+		}
+
+		hostname = strings.TrimSuffix(hostname, ".")
+		if !validHostname(strings.TrimPrefix(hostname, "*.")) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidHostname, strings.TrimSpace(line))
+		}
+
+		if !slices.Contains(hostnames, hostname) {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+
+	return hostnames, nil
+}
+
+// ExcludesIP reports whether events sent from ip are dropped.
+func (s Site) ExcludesIP(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, excluded := range s.ExcludedIPs {
+		prefix, err := parseIPPrefix(excluded)
+		if err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseExcludedIPs reads one IP address or CIDR range per non-blank line.
+// Ranges are stored by their first address, so 10.1.2.3/8 becomes 10.0.0.0/8.
+func ParseExcludedIPs(text string) ([]string, error) {
+	var ips []string
+	for line := range strings.Lines(text) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		prefix, err := parseIPPrefix(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidIPAddress, line)
+		}
+
+		ip := prefix.String()
+		if prefix.IsSingleIP() {
+			ip = prefix.Addr().String()
+		}
+
+		if !slices.Contains(ips, ip) {
+			ips = append(ips, ip)
+		}
+	}
+
+	return ips, nil
+}
+
+// parseIPPrefix reads a CIDR range, or a single IP address as a range of one.
+func parseIPPrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return prefix, err
+		}
+
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+
+	return addr.Prefix(addr.BitLen())
+}
+
+func validHostname(hostname string) bool {
+	if hostname == "" || len(hostname) > 253 {
+		return false
+	}
+
+	for label := range strings.SplitSeq(hostname, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
 type SiteRepoInterface interface {
 	ListForUser(u *User) ([]Site, error)
 	Insert(s *Site) error
 	FindForUser(u *User, id int64) (Site, error)
 	Delete(s *Site) error
 	FindByToken(token string) (Site, error)
+	AnyAllowsHostname(hostname string) (bool, error)
+	UpdateSettings(s *Site) error
 }
 
 type SiteRepo struct {
@@ -34,7 +188,7 @@ type SiteRepo struct {
 func (r *SiteRepo) ListForUser(u *User) ([]Site, error) {
 	var sites []Site
 	stmt := `
-		SELECT *, (SELECT COUNT(*) FROM events WHERE site_id = sites.id) FROM sites
+		SELECT ` + siteColumns + `, (SELECT COUNT(*) FROM events WHERE site_id = sites.id) FROM sites
 		WHERE sites.user_id = $1
 		;
 	`
@@ -47,7 +201,7 @@ func (r *SiteRepo) ListForUser(u *User) ([]Site, error) {
 	for rows.Next() {
 		var s Site
 
-		err = rows.Scan(&s.ID, &s.UserID, &s.Name, &s.Token, &s.CreatedAt, &s.UpdatedAt, &s.EventCount)
+		err = rows.Scan(scanSite(&s, &s.EventCount)...)
 		if err != nil {
 			return sites, fmt.Errorf("[SiteRepo.ListForUser] %w", err)
 		}
@@ -79,23 +233,14 @@ func (r *SiteRepo) Insert(s *Site) error {
 func (r *SiteRepo) FindForUser(u *User, id int64) (Site, error) {
 	var s Site
 	stmt := `
-SELECT *,
+SELECT ` + siteColumns + `,
 	(SELECT COUNT(*) FROM events WHERE site_id = sites.id)
 FROM sites
 WHERE user_id = $1
 	AND id = $2;
 `
 
-	err := r.db.QueryRow(stmt, u.ID, id).Scan(
-		&s.ID,
-		&s.UserID,
-		&s.Name,
-		&s.Token,
-		&s.CreatedAt,
-		&s.UpdatedAt,
-		&s.EventCount,
-	)
-
+	err := r.db.QueryRow(stmt, u.ID, id).Scan(scanSite(&s, &s.EventCount)...)
 	if err != nil {
 		return s, fmt.Errorf("[SiteRepo.FindForUser] %w", err)
 	}
@@ -114,18 +259,67 @@ func (r *SiteRepo) Delete(s *Site) error {
 
 func (r *SiteRepo) FindByToken(token string) (Site, error) {
 	var s Site
-	err := r.db.QueryRow("SELECT * FROM sites WHERE token = $1 LIMIT 1;", token).Scan(
-		&s.ID,
-		&s.UserID,
-		&s.Name,
-		&s.Token,
-		&s.CreatedAt,
-		&s.UpdatedAt,
-	)
-
+	err := r.db.QueryRow("SELECT "+siteColumns+" FROM sites WHERE token = $1 LIMIT 1;", token).Scan(scanSite(&s)...)
 	if err != nil {
 		return s, fmt.Errorf("[SiteRepo.FindByToken] %w", err)
 	}
 
 	return s, nil
 }
+
+// AnyAllowsHostname reports whether any site accepts events from pages on
+// hostname, either by listing it or by not restricting hostnames at all. It's
+// for CORS preflight requests, which don't say which site they're for.
+func (r *SiteRepo) AnyAllowsHostname(hostname string) (bool, error) {
+	stmt := `
+SELECT EXISTS (
+	SELECT 1 FROM sites
+	WHERE cardinality(allowed_hostnames) = 0
+		OR allowed_hostnames && $1
+);
+`
+
+	var ok bool
+	err := r.db.QueryRow(stmt, pq.Array(hostnamePatterns(hostname))).Scan(&ok)
+	if err != nil {
+		return false, fmt.Errorf("[SiteRepo.AnyAllowsHostname] %w", err)
+	}
+
+	return ok, nil
+}
+
+// hostnamePatterns lists the allowed hostnames that match hostname, following
+// Site.AllowsHostname: the hostname itself and a wildcard for each domain it's
+// under.
+func hostnamePatterns(hostname string) []string {
+	hostname = strings.TrimSuffix(strings.ToLower(hostname), ".")
+	patterns := []string{hostname}
+	for i := range len(hostname) {
+		if hostname[i] == '.' {
+			patterns = append(patterns, "*"+hostname[i:])
+		}
+	}
+
+	return patterns
+}
+
+// UpdateSettings saves the site's settings. pq sends a nil slice as NULL, so
+// a cleared list is stored as '{}' instead.
+func (r *SiteRepo) UpdateSettings(s *Site) error {
+	stmt := `UPDATE sites SET allowed_hostnames = COALESCE($1, '{}'), excluded_ips = COALESCE($2, '{}'), updated_at = (NOW() AT TIME ZONE 'UTC')
+	WHERE id = $3 RETURNING updated_at;`
+
+	err := r.db.QueryRow(stmt, pq.Array(s.AllowedHostnames), pq.Array(s.ExcludedIPs), s.ID).Scan(&s.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("[SiteRepo.UpdateSettings] %w", err)
+	}
+
+	return nil
+}
+
+// scanSite returns the destinations for siteColumns, followed by any extra
+// columns the query selects.
+func scanSite(s *Site, extra ...any) []any {
+	dest := []any{&s.ID, &s.UserID, &s.Name, &s.Token, pq.Array(&s.AllowedHostnames), pq.Array(&s.ExcludedIPs), &s.CreatedAt, &s.UpdatedAt}
+	return append(dest, extra...)
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+)
+
+func TestParseAllowedHostnames(t *testing.T) {
+	hostnames, err := ParseAllowedHostnames("Example.com\n\n  *.example.org \r\nhttps://shop.example.com:8443/cart\nexample.com.\n")
+	assert.Nil(t, err)
+	assert.Equal(t, len(hostnames), 3)
+	assert.Equal(t, hostnames[0], "example.com")
+	assert.Equal(t, hostnames[1], "*.example.org")
+	assert.Equal(t, hostnames[2], "shop.example.com")
+
+	for _, text := range []string{"example com", "*.", "-example.com", "exa_mple.com", "*example.com", "example..com"} {
+		_, err := ParseAllowedHostnames(text)
+		assert.Equal(t, errors.Is(err, ErrInvalidHostname), true)
+	}
+}
+
+func TestSiteAllowsHostname(t *testing.T) {
+	assert.Equal(t, Site{}.AllowsHostname("anything.test"), true)
+
+	site := Site{AllowedHostnames: []string{"example.com", "*.example.org"}}
+	tests := []struct {
+		hostname string
+		want     bool
+	}{
+		{hostname: "example.com", want: true},
+		{hostname: "EXAMPLE.com.", want: true},
+		{hostname: "www.example.com", want: false},
+		{hostname: "www.example.org", want: true},
+		{hostname: "a.b.example.org", want: true},
+		{hostname: "example.org", want: false},
+		{hostname: "badexample.org", want: false},
+		{hostname: "", want: false},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, site.AllowsHostname(tc.hostname), tc.want)
+	}
+}
+
+func TestParseExcludedIPs(t *testing.T) {
+	ips, err := ParseExcludedIPs(" 203.0.113.7 \n\n198.51.100.99/24\r\n2001:db8::1/32\n203.0.113.7/32\n::ffff:192.0.2.1\n")
+	assert.Nil(t, err)
+	assert.Equal(t, strings.Join(ips, ","), "203.0.113.7,198.51.100.0/24,2001:db8::/32,192.0.2.1")
+
+	for _, text := range []string{"203.0.113", "203.0.113.7/33", "office", "203.0.113.0 - 203.0.113.255"} {
+		_, err := ParseExcludedIPs(text)
+		assert.Equal(t, errors.Is(err, ErrInvalidIPAddress), true)
+	}
+}
+
+func TestSiteExcludesIP(t *testing.T) {
+	assert.Equal(t, Site{}.ExcludesIP("203.0.113.7"), false)
+
+	site := Site{ExcludedIPs: []string{"203.0.113.7", "198.51.100.0/24", "2001:db8::/32"}}
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "203.0.113.7", want: true},
+		{ip: "203.0.113.8", want: false},
+		{ip: "198.51.100.200", want: true},
+		{ip: "::ffff:198.51.100.1", want: true},
+		{ip: "2001:db8:1::1", want: true},
+		{ip: "2001:db9::1", want: false},
+		{ip: "", want: false},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, site.ExcludesIP(tc.ip), tc.want)
+	}
+}
+
+func TestSiteAllowsHostnamePatterns(t *testing.T) {
+	assert.Equal(t, strings.Join(hostnamePatterns("Blog.Example.com."), " "), "blog.example.com *.example.com *.com")
+	assert.Equal(t, strings.Join(hostnamePatterns("localhost"), " "), "localhost")
+}
+
+func TestSiteRepoUpdateSettings(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	r := SiteRepo{db: db}
+	site := setupSite(t, db)
+
+	found, err := r.FindByToken(site.Token)
+	assert.Nil(t, err)
+	assert.Equal(t, len(found.AllowedHostnames), 0)
+	assert.Equal(t, len(found.ExcludedIPs), 0)
+
+	site.AllowedHostnames = []string{"example.com", "*.example.org"}
+	site.ExcludedIPs = []string{"203.0.113.7", "198.51.100.0/24"}
+	assert.Nil(t, r.UpdateSettings(site))
+	assert.Equal(t, time.Since(site.UpdatedAt).Abs() < time.Minute, true)
+
+	found, err = r.FindByToken(site.Token)
+	assert.Nil(t, err)
+	assert.Equal(t, strings.Join(found.AllowedHostnames, " "), "example.com *.example.org")
+	assert.Equal(t, strings.Join(found.ExcludedIPs, " "), "203.0.113.7 198.51.100.0/24")
+	assert.Equal(t, found.UpdatedAt.Equal(site.UpdatedAt), true)
+
+	t.Run("Allow every hostname again", func(t *testing.T) {
+		site.AllowedHostnames = nil
+		assert.Nil(t, r.UpdateSettings(site))
+
+		found, err := r.FindByToken(site.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, len(found.AllowedHostnames), 0)
+	})
+
+	t.Run("Stop excluding IPs", func(t *testing.T) {
+		site.ExcludedIPs = nil
+		assert.Nil(t, r.UpdateSettings(site))
+
+		found, err := r.FindByToken(site.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, len(found.ExcludedIPs), 0)
+	})
+}
+
+func TestSiteRepoAnyAllowsHostname(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	r := SiteRepo{db: db}
+
+	site := setupSite(t, db)
+	site.AllowedHostnames = []string{"example.com", "*.example.org"}
+	assert.Nil(t, r.UpdateSettings(site))
+
+	for hostname, want := range map[string]bool{
+		"example.com":      true,
+		"Blog.Example.org": true,
+		"example.org":      false,
+		"example.net":      false,
+	} {
+		t.Run(hostname, func(t *testing.T) {
+			ok, err := r.AnyAllowsHostname(hostname)
+			assert.Nil(t, err)
+			assert.Equal(t, ok, want)
+		})
+	}
+
+	t.Run("A site that allows every hostname", func(t *testing.T) {
+		setupSite(t, db)
+		ok, err := r.AnyAllowsHostname("example.net")
+		assert.Nil(t, err)
+		assert.Equal(t, ok, true)
+	})
+}
@@ -2,14 +2,21 @@ package models
 
 import (
 	"database/sql"
+	"fmt"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
 	"slices"
 	"testing"
+	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/robyparr/event-horizon/internal/assert"
 )
 
+// testNow is midday on the last day of the ranges the event tests query.
+var testNow = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
 func newTestDB(t *testing.T) *sql.DB {
 	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL_TEST"))
 	if err != nil {
@@ -63,3 +70,31 @@ func newTestDB(t *testing.T) *sql.DB {
 
 	return db
 }
+
+func setupSite(t *testing.T, db *sql.DB) *Site {
+	user := &User{Email: fmt.Sprintf("%d%s", rand.Uint64(), "@example.com"), Password: "pa$$word", Timezone: "UTC"}
+	assert.Nil(t, (&UserRepo{db: db}).Insert(user))
+
+	site := &Site{UserID: user.ID, Name: "Test Site"}
+	assert.Nil(t, (&SiteRepo{db: db}).Insert(site))
+	return site
+}
+
+func setupEventRepo(t *testing.T) (*Site, *EventRepo) {
+	db := newTestDB(t)
+	return setupSite(t, db), &EventRepo{db: db}
+}
+
+func insertEvents(t *testing.T, r *EventRepo, events ...*Event) {
+	assert.Nil(t, r.InsertMany(events))
+}
+
+func visitor(id string) sql.NullString {
+	return sql.NullString{String: id, Valid: true}
+}
+
+func todayQuery(t *testing.T) EventQuery {
+	dr, err := NewDateRange(RangeToday, testNow, time.UTC)
+	assert.Nil(t, err)
+	return EventQuery{Range: dr}
+}
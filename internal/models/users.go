@@ -25,6 +25,18 @@ type User struct {
 	Password string
 }
 
+// Location is the user's time zone, falling back to UTC when it's unset or
+// unknown. "Local" is Go's name for the server's zone, which Postgres can't
+// use in AT TIME ZONE, so it falls back too.
+func (u *User) Location() *time.Location {
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil || loc == time.Local {
+		return time.UTC
+	}
+
+	return loc
+}
+
 type UserRepo struct {
 	db *sql.DB
 }
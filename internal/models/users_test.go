@@ -76,3 +76,17 @@ func TestUserAuthenticate(t *testing.T) {
 		assert.Equal(t, id, 0)
 	})
 }
+
+func TestUserLocation(t *testing.T) {
+	for timezone, want := range map[string]string{
+		"America/Vancouver": "America/Vancouver",
+		"":                  "UTC",
+		"Local":             "UTC",
+		"Mars/Olympus_Mons": "UTC",
+	} {
+		t.Run(timezone, func(t *testing.T) {
+			user := User{Timezone: timezone}
+			assert.Equal(t, user.Location().String(), want)
+		})
+	}
+}
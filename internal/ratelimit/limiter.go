@@ -0,0 +1,89 @@
+// Package ratelimit limits how often something identified by a key can be
+// done, such as requests from an IP address.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit allows Requests every Per, in bursts of up to Requests. A Limit with
+// no Requests allows everything.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+func (l Limit) enabled() bool {
+	return l.Requests > 0 && l.Per > 0
+}
+
+// perSecond is how quickly used requests are given back.
+func (l Limit) perSecond() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// Limiter is a token bucket per key. Buckets that have refilled are swept
+// away, so memory only grows with the keys seen recently.
+type Limiter struct {
+	mu        sync.Mutex
+	limit     Limit
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewLimiter(limit Limit) *Limiter {
+	return &Limiter{limit: limit, buckets: make(map[string]*bucket)}
+}
+
+// Allow uses one of key's requests as of now. When there are none left it
+// returns false, along with how long until there will be.
+func (l *Limiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	if !l.limit.enabled() {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= l.limit.Per {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Requests), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = l.refill(b, now)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := (1 - b.tokens) / l.limit.perSecond()
+	return false, time.Duration(math.Ceil(wait * float64(time.Second)))
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	elapsed := max(now.Sub(b.last).Seconds(), 0)
+	return min(b.tokens+elapsed*l.limit.perSecond(), float64(l.limit.Requests))
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= float64(l.limit.Requests) {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.lastSweep = now
+}
@@ -0,0 +1,44 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(Limit{Requests: 2, Per: time.Minute})
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	for range 2 {
+		ok, _ := l.Allow("a", now)
+		assert.Equal(t, ok, true)
+	}
+
+	ok, retryAfter := l.Allow("a", now)
+	assert.Equal(t, ok, false)
+	assert.Equal(t, retryAfter, 30*time.Second)
+
+	ok, _ = l.Allow("b", now)
+	assert.Equal(t, ok, true)
+
+	ok, _ = l.Allow("a", now.Add(30*time.Second))
+	assert.Equal(t, ok, true)
+	ok, retryAfter = l.Allow("a", now.Add(45*time.Second))
+	assert.Equal(t, ok, false)
+	assert.Equal(t, retryAfter, 15*time.Second)
+
+	t.Run("Sweep", func(t *testing.T) {
+		l.Allow("c", now.Add(2*time.Minute))
+		assert.Equal(t, len(l.buckets), 1)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		l := NewLimiter(Limit{})
+		for range 100 {
+			ok, _ := l.Allow("a", now)
+			assert.Equal(t, ok, true)
+		}
+	})
+}
@@ -0,0 +1,39 @@
+package internal
+
+import (
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/ratelimit"
+)
+
+// RateLimitToken and RateLimitIP are the reasons requests over a budget are
+// counted as rejected.
+const (
+	RateLimitToken = "rate_limit_token"
+	RateLimitIP    = "rate_limit_ip"
+)
+
+// RateLimits are the ingestion API's budgets.
+type RateLimits struct {
+	Token *ratelimit.Limiter
+	IP    *ratelimit.Limiter
+}
+
+func NewRateLimits(token ratelimit.Limit, ip ratelimit.Limit) *RateLimits {
+	return &RateLimits{
+		Token: ratelimit.NewLimiter(token),
+		IP:    ratelimit.NewLimiter(ip),
+	}
+}
+
+// AllowIP checks a request from ip against its budget, returning when to
+// retry if it's used up.
+func (rl *RateLimits) AllowIP(ip string) (bool, time.Duration) {
+	return rl.IP.Allow(ip, time.Now())
+}
+
+// AllowToken checks a request for the site with siteToken against the
+// site's budget, returning when to retry if it's used up.
+func (rl *RateLimits) AllowToken(siteToken string) (bool, time.Duration) {
+	return rl.Token.Allow(siteToken, time.Now())
+}
@@ -0,0 +1,83 @@
+// Package realtime lets the dashboard follow a site's events as they're
+// recorded.
+package realtime
+
+import "sync"
+
+// Broker is an in-process pub/sub of sites that have new events. Messages
+// only say that a site changed, so subscribers that fall behind can skip
+// straight to the latest state rather than being sent every event.
+type Broker struct {
+	mu          sync.Mutex
+	closed      bool
+	subscribers map[int64]map[chan struct{}]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[int64]map[chan struct{}]struct{})}
+}
+
+// Subscribe returns a channel that receives a value after site siteID has new
+// events, and a function to stop receiving them. Notifications that arrive
+// while one is already waiting are merged into it. The channel is closed when
+// the broker is.
+func (b *Broker) Subscribe(siteID int64) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	if b.subscribers[siteID] == nil {
+		b.subscribers[siteID] = make(map[chan struct{}]struct{})
+	}
+	b.subscribers[siteID][ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subscribers[siteID][ch]; !ok {
+			return
+		}
+
+		delete(b.subscribers[siteID], ch)
+		if len(b.subscribers[siteID]) == 0 {
+			delete(b.subscribers, siteID)
+		}
+		close(ch)
+	}
+}
+
+// Publish notifies site siteID's subscribers that it has new events. It never
+// blocks on slow subscribers.
+func (b *Broker) Publish(siteID int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[siteID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Close ends every subscription, so long-lived streams finish and the server
+// can shut down.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for _, subscribers := range b.subscribers {
+		for ch := range subscribers {
+			close(ch)
+		}
+	}
+	b.subscribers = make(map[int64]map[chan struct{}]struct{})
+}
@@ -0,0 +1,37 @@
+package realtime
+
+import (
+	"testing"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+)
+
+func TestBroker(t *testing.T) {
+	b := NewBroker()
+	site1, unsubscribe1 := b.Subscribe(1)
+	site2, unsubscribe2 := b.Subscribe(2)
+	defer unsubscribe2()
+
+	b.Publish(1)
+	b.Publish(1)
+
+	_, ok := <-site1
+	assert.Equal(t, ok, true)
+	assert.Equal(t, len(site1), 0)
+	assert.Equal(t, len(site2), 0)
+
+	unsubscribe1()
+	_, ok = <-site1
+	assert.Equal(t, ok, false)
+	b.Publish(1)
+	unsubscribe1()
+
+	b.Close()
+	_, ok = <-site2
+	assert.Equal(t, ok, false)
+
+	closed, unsubscribe := b.Subscribe(3)
+	_, ok = <-closed
+	assert.Equal(t, ok, false)
+	unsubscribe()
+}
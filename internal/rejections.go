@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"sync"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/models"
+)
+
+// RejectionSaveInterval is how often the rejected request counts are saved.
+const RejectionSaveInterval = time.Minute
+
+// Rejections tallies the events API requests turned away from each site until
+// SaveRejections adds them to the database, so counting a request doesn't
+// wait on it. Only the tokens of sites that have been looked up are counted,
+// so requests with made-up tokens can't grow the tally.
+type Rejections struct {
+	mu     sync.Mutex
+	sites  map[string]bool
+	counts map[models.RejectionKey]int
+}
+
+func NewRejections() *Rejections {
+	return &Rejections{sites: make(map[string]bool), counts: make(map[models.RejectionKey]int)}
+}
+
+// Track notes that siteToken belongs to a site, so requests turned away
+// before their site is looked up can be counted against it.
+func (rs *Rejections) Track(siteToken string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.sites[siteToken] = true
+}
+
+// Add counts a request sent with siteToken that was turned away for reason,
+// unless the token hasn't been tracked.
+func (rs *Rejections) Add(siteToken string, reason string) {
+	key := models.RejectionKey{SiteToken: siteToken, Day: time.Now().UTC().Format(time.DateOnly), Reason: reason}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.sites[siteToken] {
+		rs.counts[key] += 1
+	}
+}
+
+// take empties the tally, returning what was in it.
+func (rs *Rejections) take() map[models.RejectionKey]int {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	counts := rs.counts
+	rs.counts = make(map[models.RejectionKey]int)
+	return counts
+}
+
+// restore adds counts that couldn't be saved back to the tally.
+func (rs *Rejections) restore(counts map[models.RejectionKey]int) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	for key, count := range counts {
+		rs.counts[key] += count
+	}
+}
+
+// SaveRejections adds the rejected requests tallied since it last ran to the
+// database. They're kept for next time if that fails.
+func (app *App) SaveRejections() error {
+	counts := app.Rejections.take()
+	err := app.Repos.Rejections.AddCounts(counts)
+	if err != nil {
+		app.Rejections.restore(counts)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+	"github.com/robyparr/event-horizon/internal/models"
+	"github.com/robyparr/event-horizon/internal/models/mocks"
+)
+
+func TestSaveRejections(t *testing.T) {
+	rejectionRepo := mocks.NewRejectionRepo()
+	app := &App{
+		Repos:      &models.Repos{Rejections: rejectionRepo},
+		Rejections: NewRejections(),
+	}
+
+	app.Rejections.Track("site-token")
+	app.Rejections.Add("site-token", RateLimitIP)
+	app.Rejections.Add("site-token", RateLimitIP)
+	app.Rejections.Add("site-token", RateLimitToken)
+	app.Rejections.Add("no-such-token", RateLimitIP)
+	app.Rejections.Add("", RateLimitIP)
+	assert.Nil(t, app.SaveRejections())
+
+	today := time.Now().UTC().Format(time.DateOnly)
+	assert.Equal(t, len(rejectionRepo.Counts), 2)
+	assert.Equal(t, rejectionRepo.Counts[models.RejectionKey{SiteToken: "site-token", Day: today, Reason: RateLimitIP}], 2)
+	assert.Equal(t, rejectionRepo.Counts[models.RejectionKey{SiteToken: "site-token", Day: today, Reason: RateLimitToken}], 1)
+
+	t.Run("Counts are only saved once", func(t *testing.T) {
+		app.Rejections.Add("site-token", RateLimitIP)
+		assert.Nil(t, app.SaveRejections())
+		assert.Equal(t, rejectionRepo.Counts[models.RejectionKey{SiteToken: "site-token", Day: today, Reason: RateLimitIP}], 3)
+	})
+}
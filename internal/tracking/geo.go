@@ -0,0 +1,78 @@
+package tracking
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Location is roughly where a visitor is, as resolved from their IP address.
+// Country is an ISO 3166-1 code, and Region and City are English names.
+type Location struct {
+	Country string
+	Region  string
+	City    string
+}
+
+// GeoDB resolves IP addresses to locations using a local MaxMind or DB-IP
+// compatible .mmdb database, so no IP ever leaves the server. A nil GeoDB
+// resolves every address to an empty location.
+type GeoDB struct {
+	reader *maxminddb.Reader
+}
+
+type geoRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+func OpenGeoDB(path string) (*GeoDB, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("[tracking.OpenGeoDB] %w", err)
+	}
+
+	return &GeoDB{reader: reader}, nil
+}
+
+// Lookup returns the location of ip. Addresses that can't be parsed or
+// aren't in the database have an empty location.
+func (g *GeoDB) Lookup(ip string) Location {
+	if g == nil {
+		return Location{}
+	}
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return Location{}
+	}
+
+	var record geoRecord
+	err := g.reader.Lookup(parsed, &record)
+	if err != nil {
+		return Location{}
+	}
+
+	location := Location{Country: record.Country.ISOCode, City: record.City.Names["en"]}
+	if len(record.Subdivisions) > 0 {
+		location.Region = record.Subdivisions[0].Names["en"]
+	}
+
+	return location
+}
+
+func (g *GeoDB) Close() error {
+	if g == nil {
+		return nil
+	}
+
+	return g.reader.Close()
+}
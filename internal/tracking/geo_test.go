@@ -0,0 +1,20 @@
+package tracking
+
+import (
+	"testing"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+)
+
+func TestGeoDB(t *testing.T) {
+	t.Run("Missing database", func(t *testing.T) {
+		_, err := OpenGeoDB("testdata/missing.mmdb")
+		assert.Equal(t, err != nil, true)
+	})
+
+	t.Run("Not configured", func(t *testing.T) {
+		var g *GeoDB
+		assert.Equal(t, g.Lookup("81.2.69.142"), Location{})
+		assert.Nil(t, g.Close())
+	})
+}
@@ -0,0 +1,116 @@
+// Package tracking cleans up the page and referrer details that the tracking
+// script reports with each event.
+package tracking
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// AllowedQueryParams are the only query parameters kept on page paths. Query
+// strings often carry tokens or personal details, and would otherwise split
+// one page into countless variants.
+var AllowedQueryParams = []string{"id", "lang", "p", "page", "q", "query", "s", "search", "tab"}
+
+const (
+	maxHostnameChars = 253
+	maxPathChars     = 1024
+)
+
+var ErrInvalidURL = errors.New("tracking: invalid URL")
+
+// Page is where an event was recorded.
+type Page struct {
+	Hostname string
+	Path     string
+}
+
+// ParsePage normalizes the page an event was recorded on. rawURL is the full
+// URL of the page, while hostname and path take precedence over the URL's
+// when they're given.
+func ParsePage(rawURL string, hostname string, pagePath string) (Page, error) {
+	var page Page
+	if rawURL != "" {
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Host == "" {
+			return page, ErrInvalidURL
+		}
+
+		page.Hostname = u.Host
+		page.Path = u.RequestURI()
+	}
+
+	if hostname != "" {
+		page.Hostname = hostname
+	}
+
+	if pagePath != "" {
+		page.Path = pagePath
+	}
+
+	page.Hostname = NormalizeHostname(page.Hostname)
+	if page.Path != "" {
+		p, err := NormalizePath(page.Path)
+		if err != nil {
+			return page, err
+		}
+		page.Path = p
+	}
+
+	return page, nil
+}
+
+// NormalizeHostname lowercases a hostname and strips its port and any
+// trailing dot.
+func NormalizeHostname(hostname string) string {
+	hostname = strings.ToLower(strings.TrimSpace(hostname))
+	if u, err := url.Parse("//" + hostname); err == nil {
+		hostname = u.Hostname()
+	}
+
+	return truncate(strings.TrimSuffix(hostname, "."), maxHostnameChars)
+}
+
+// NormalizePath cleans up a page path, dropping its fragment, trailing slash
+// and any query parameters that aren't allowed.
+func NormalizePath(rawPath string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawPath))
+	if err != nil {
+		return "", ErrInvalidURL
+	}
+
+	p := path.Clean("/" + u.Path)
+
+	query := u.Query()
+	for key := range query {
+		if !slices.Contains(AllowedQueryParams, key) {
+			query.Del(key)
+		}
+	}
+
+	if len(query) > 0 {
+		p += "?" + query.Encode()
+	}
+
+	return truncate(p, maxPathChars), nil
+}
+
+// truncate makes s valid UTF-8, since decoded URLs can be anything, and cuts
+// it down to at most n bytes without splitting a character. Postgres rejects
+// text that isn't valid UTF-8.
+func truncate(s string, n int) string {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
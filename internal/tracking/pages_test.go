@@ -0,0 +1,76 @@
+package tracking
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+)
+
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		hostname string
+		path     string
+		want     Page
+		wantErr  error
+	}{
+		{
+			name: "Full URL",
+			url:  "https://Example.com:8443/docs/intro/?utm_source=news&page=2&token=secret#setup",
+			want: Page{Hostname: "example.com", Path: "/docs/intro?page=2"},
+		},
+		{
+			name: "Root",
+			url:  "https://example.com",
+			want: Page{Hostname: "example.com", Path: "/"},
+		},
+		{
+			name:     "Explicit hostname and path",
+			url:      "https://example.com/ignored",
+			hostname: "WWW.example.com.",
+			path:     "pricing//plans/../teams/",
+			want:     Page{Hostname: "www.example.com", Path: "/pricing/teams"},
+		},
+		{
+			name: "Allowed params are sorted",
+			path: "/search?q=shoes&lang=en&sessionid=abc",
+			want: Page{Path: "/search?lang=en&q=shoes"},
+		},
+		{
+			name: "Invalid UTF-8",
+			url:  "https://example.com/caf%C3",
+			want: Page{Hostname: "example.com", Path: "/caf\uFFFD"},
+		},
+		{
+			name: "Long path",
+			path: "/" + strings.Repeat("é", 600),
+			want: Page{Path: "/" + strings.Repeat("é", 511)},
+		},
+		{
+			name:    "Relative URL",
+			url:     "/docs",
+			wantErr: ErrInvalidURL,
+		},
+		{
+			name:    "Unparseable URL",
+			url:     "https://example.com/%zz",
+			wantErr: ErrInvalidURL,
+		},
+		{
+			name: "Nothing given",
+			want: Page{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := ParsePage(tt.url, tt.hostname, tt.path)
+			assert.Equal(t, err, tt.wantErr)
+			if tt.wantErr == nil {
+				assert.Equal(t, page, tt.want)
+			}
+		})
+	}
+}
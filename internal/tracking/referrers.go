@@ -0,0 +1,176 @@
+package tracking
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	ChannelDirect        = "Direct"
+	ChannelOrganicSearch = "Organic Search"
+	ChannelSocial        = "Social"
+	ChannelEmail         = "Email"
+	ChannelPaid          = "Paid"
+	ChannelReferral      = "Referral"
+)
+
+const maxReferrerURLChars = 2048
+
+//go:embed referrer_sources.json
+var defaultSourcesJSON []byte
+
+// DefaultSources is the built-in list of known referrer sources, used unless
+// a replacement list is configured.
+var DefaultSources = sync.OnceValue(func() *Sources {
+	sources, err := LoadSources(bytes.NewReader(defaultSourcesJSON))
+	if err != nil {
+		panic(err)
+	}
+
+	return sources
+})
+
+// Referrer is the page a visitor followed a link from. Host has its port and
+// any "www." prefix removed, and URL keeps only the allowed query
+// parameters.
+type Referrer struct {
+	Host string
+	URL  string
+}
+
+// ParseReferrer normalizes a referrer. Older versions of eh.js only send the
+// referrer's host, so a referrer without a scheme is read as a host.
+func ParseReferrer(rawURL string) (Referrer, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return Referrer{}, nil
+	}
+
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return Referrer{}, ErrInvalidURL
+	}
+
+	host := strings.TrimPrefix(NormalizeHostname(u.Host), "www.")
+	if host == "" {
+		return Referrer{}, ErrInvalidURL
+	}
+
+	refPath, err := NormalizePath(u.RequestURI())
+	if err != nil {
+		return Referrer{}, err
+	}
+
+	refURL := truncate(u.Scheme+"://"+host+refPath, maxReferrerURLChars)
+	return Referrer{Host: host, URL: refURL}, nil
+}
+
+// IsInternal reports whether the referrer is the same site as page, e.g.
+// when a visitor follows a link from one page to another.
+func (r Referrer) IsInternal(page Page) bool {
+	return r.Host != "" && r.Host == strings.TrimPrefix(page.Hostname, "www.")
+}
+
+// Source is a well-known site that visitors are referred from.
+type Source struct {
+	Name    string   `json:"name"`
+	Channel string   `json:"channel"`
+	Hosts   []string `json:"hosts"`
+}
+
+// Sources looks up referrer hosts and utm_source values in a list of known
+// sources.
+type Sources struct {
+	byHost map[string]Source
+	byName map[string]Source
+}
+
+// LoadSources reads a JSON list of sources in the same format as the
+// built-in referrer_sources.json.
+func LoadSources(r io.Reader) (*Sources, error) {
+	var list []Source
+	err := json.NewDecoder(r).Decode(&list)
+	if err != nil {
+		return nil, fmt.Errorf("[tracking.LoadSources] %w", err)
+	}
+
+	sources := &Sources{byHost: make(map[string]Source), byName: make(map[string]Source)}
+	for _, source := range list {
+		if source.Name == "" || len(source.Hosts) == 0 {
+			return nil, errors.New("[tracking.LoadSources] every source needs a name and at least one host")
+		}
+
+		sources.byName[strings.ToLower(source.Name)] = source
+		for _, host := range source.Hosts {
+			sources.byHost[strings.TrimPrefix(NormalizeHostname(host), "www.")] = source
+		}
+	}
+
+	return sources, nil
+}
+
+// Lookup finds the source for a referrer host, including its subdomains, or
+// for a utm_source value naming one, e.g. "google" or "Hacker News".
+func (s *Sources) Lookup(hostOrName string) (Source, bool) {
+	if source, ok := s.byName[strings.ToLower(strings.TrimSpace(hostOrName))]; ok {
+		return source, true
+	}
+
+	host := strings.TrimPrefix(NormalizeHostname(hostOrName), "www.")
+	for host != "" {
+		if source, ok := s.byHost[host]; ok {
+			return source, true
+		}
+
+		_, host, _ = strings.Cut(host, ".")
+	}
+
+	return Source{}, false
+}
+
+// Channel classifies how a visitor arrived. Campaign mediums take precedence
+// over the referrer, since a paid Google ad is still referred by Google.
+func (s *Sources) Channel(ref Referrer, utm UTM) string {
+	medium := strings.ToLower(utm.Medium)
+	if isPaidMedium(medium) {
+		return ChannelPaid
+	}
+
+	source, ok := s.Lookup(ref.Host)
+	if !ok && utm.Source != "" {
+		source, _ = s.Lookup(utm.Source)
+	}
+
+	switch {
+	case medium == "email" || medium == "newsletter" || source.Channel == ChannelEmail:
+		return ChannelEmail
+	case medium == "social" || source.Channel == ChannelSocial:
+		return ChannelSocial
+	case medium == "organic" || source.Channel == ChannelOrganicSearch:
+		return ChannelOrganicSearch
+	case ref.Host != "" || utm.Source != "":
+		return ChannelReferral
+	default:
+		return ChannelDirect
+	}
+}
+
+func isPaidMedium(medium string) bool {
+	switch medium {
+	case "cpc", "cpm", "cpv", "cpa", "ppc", "display", "banner", "retargeting":
+		return true
+	default:
+		return strings.HasPrefix(medium, "paid")
+	}
+}
@@ -0,0 +1,94 @@
+package tracking
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+)
+
+func TestParseReferrer(t *testing.T) {
+	tests := []struct {
+		name    string
+		rawURL  string
+		want    Referrer
+		wantErr error
+	}{
+		{
+			name:   "Full URL",
+			rawURL: "https://www.Example.com:8443/blog/post/?ref=abc&q=go#comments",
+			want:   Referrer{Host: "example.com", URL: "https://example.com/blog/post?q=go"},
+		},
+		{
+			name:   "Host only",
+			rawURL: "news.ycombinator.com",
+			want:   Referrer{Host: "news.ycombinator.com", URL: "https://news.ycombinator.com/"},
+		},
+		{
+			name:   "Blank",
+			rawURL: "",
+			want:   Referrer{},
+		},
+		{
+			name:    "Invalid",
+			rawURL:  "https://exa mple.com",
+			wantErr: ErrInvalidURL,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseReferrer(tt.rawURL)
+			assert.Equal(t, err, tt.wantErr)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestSourcesChannel(t *testing.T) {
+	sources := DefaultSources()
+
+	tests := []struct {
+		name     string
+		referrer string
+		utm      UTM
+		want     string
+	}{
+		{name: "Direct", want: ChannelDirect},
+		{name: "Search engine", referrer: "www.google.co.uk", want: ChannelOrganicSearch},
+		{name: "Search subdomain", referrer: "cn.bing.com", want: ChannelOrganicSearch},
+		{name: "Social", referrer: "https://old.reddit.com/r/golang", want: ChannelSocial},
+		{name: "Webmail", referrer: "https://mail.google.com/mail/u/0/", want: ChannelEmail},
+		{name: "Other site", referrer: "https://blog.example.com/", want: ChannelReferral},
+		{name: "Paid search", referrer: "google.com", utm: UTM{Source: "google", Medium: "cpc"}, want: ChannelPaid},
+		{name: "Paid social", utm: UTM{Source: "facebook", Medium: "paid_social"}, want: ChannelPaid},
+		{name: "Paid impressions", utm: UTM{Source: "news", Medium: "cpm"}, want: ChannelPaid},
+		{name: "Medium starting with cp", referrer: "example.com", utm: UTM{Source: "example", Medium: "copy"}, want: ChannelReferral},
+		{name: "Email campaign", utm: UTM{Source: "newsletter", Medium: "Email"}, want: ChannelEmail},
+		{name: "Known campaign source", utm: UTM{Source: "Twitter"}, want: ChannelSocial},
+		{name: "Unknown campaign source", utm: UTM{Source: "partner"}, want: ChannelReferral},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := ParseReferrer(tt.referrer)
+			assert.Nil(t, err)
+			assert.Equal(t, sources.Channel(ref, tt.utm), tt.want)
+		})
+	}
+}
+
+func TestLoadSources(t *testing.T) {
+	sources, err := LoadSources(strings.NewReader(`[{"name": "Example", "channel": "Social", "hosts": ["example.com"]}]`))
+	assert.Nil(t, err)
+
+	source, ok := sources.Lookup("www.example.com")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, source.Name, "Example")
+
+	_, ok = sources.Lookup("google.com")
+	assert.Equal(t, ok, false)
+
+	_, err = LoadSources(strings.NewReader(`[{"name": "Example"}]`))
+	assert.Equal(t, err != nil, true)
+}
@@ -0,0 +1,57 @@
+package tracking
+
+import (
+	"net/url"
+	"strings"
+)
+
+const maxUTMChars = 255
+
+// UTM holds the campaign parameters marketing links are tagged with.
+type UTM struct {
+	Source   string `json:"source"`
+	Medium   string `json:"medium"`
+	Campaign string `json:"campaign"`
+	Term     string `json:"term"`
+	Content  string `json:"content"`
+}
+
+// IsZero reports whether none of the campaign parameters are set.
+func (u UTM) IsZero() bool {
+	return u == UTM{}
+}
+
+// Normalize trims each parameter and cuts it down to the maximum length.
+func (u UTM) Normalize() UTM {
+	return UTM{
+		Source:   truncateUTM(u.Source),
+		Medium:   truncateUTM(u.Medium),
+		Campaign: truncateUTM(u.Campaign),
+		Term:     truncateUTM(u.Term),
+		Content:  truncateUTM(u.Content),
+	}
+}
+
+// ParseUTM reads the utm_* parameters from a page URL or path. Anything
+// that can't be parsed has no campaign parameters.
+func ParseUTM(rawURL string) UTM {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return UTM{}
+	}
+
+	query := u.Query()
+	utm := UTM{
+		Source:   query.Get("utm_source"),
+		Medium:   query.Get("utm_medium"),
+		Campaign: query.Get("utm_campaign"),
+		Term:     query.Get("utm_term"),
+		Content:  query.Get("utm_content"),
+	}
+
+	return utm.Normalize()
+}
+
+func truncateUTM(value string) string {
+	return truncate(strings.TrimSpace(value), maxUTMChars)
+}
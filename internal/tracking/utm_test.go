@@ -0,0 +1,53 @@
+package tracking
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/robyparr/event-horizon/internal/assert"
+)
+
+func TestParseUTM(t *testing.T) {
+	tests := []struct {
+		name   string
+		rawURL string
+		want   UTM
+	}{
+		{
+			name:   "All parameters",
+			rawURL: "https://example.com/?utm_source=newsletter&utm_medium=email&utm_campaign=launch&utm_term=analytics&utm_content=header",
+			want:   UTM{Source: "newsletter", Medium: "email", Campaign: "launch", Term: "analytics", Content: "header"},
+		},
+		{
+			name:   "Path only",
+			rawURL: "/pricing?utm_source=twitter&utm_campaign=%20spring%20sale%20",
+			want:   UTM{Source: "twitter", Campaign: "spring sale"},
+		},
+		{
+			name:   "No parameters",
+			rawURL: "https://example.com/blog",
+			want:   UTM{},
+		},
+		{
+			name:   "Invalid URL",
+			rawURL: "https://exa mple.com/%zz",
+			want:   UTM{},
+		},
+		{
+			name:   "Long value",
+			rawURL: "/?utm_campaign=" + strings.Repeat("a", 300),
+			want:   UTM{Campaign: strings.Repeat("a", 255)},
+		},
+		{
+			name:   "Invalid UTF-8",
+			rawURL: "/?utm_source=%FFnews&utm_campaign=" + strings.Repeat("é", 200),
+			want:   UTM{Source: "\uFFFDnews", Campaign: strings.Repeat("é", 127)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, ParseUTM(tt.rawURL), tt.want)
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"regexp"
 	"slices"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
@@ -58,3 +59,15 @@ func MinChars(value string, n int) bool {
 func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
+
+// TimeZone reports whether value names a zone in the IANA database, e.g.
+// "America/Vancouver". Go also accepts "" and "Local" for the server's zone,
+// which Postgres doesn't understand.
+func TimeZone(value string) bool {
+	if value == "" || value == "Local" {
+		return false
+	}
+
+	_, err := time.LoadLocation(value)
+	return err == nil
+}
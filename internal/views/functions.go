@@ -3,7 +3,10 @@ package views
 import (
 	"fmt"
 	"html/template"
+	"maps"
 	"math"
+	"net/url"
+	"slices"
 	"time"
 )
 
@@ -14,6 +17,12 @@ var functions = template.FuncMap{
 	"humanDate":     humanDate,
 	"humanDatetime": humanDatetime,
 	"humanTimeDiff": humanTimeDiff,
+	"humanDuration": humanDuration,
+	"delta":         delta,
+	"deltaClass":    deltaClass,
+
+	"withQueryValue":    withQueryValue,
+	"withoutQueryValue": withoutQueryValue,
 }
 
 func humanDate(tz string, t time.Time) string {
@@ -81,6 +90,18 @@ func humanTimeDiff(t time.Time) string {
 	return fmt.Sprintf("%d %s %s", vInt, pluralize(timeUnit, vInt), relativeWord)
 }
 
+func humanDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm %02ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%dh %02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
+
 func pluralize(word string, n int) string {
 	if n == 1 {
 		return word
@@ -88,3 +109,81 @@ func pluralize(word string, n int) string {
 
 	return word + "s"
 }
+
+// delta describes the change from previous to current, e.g. "+5 (+50%)". It
+// takes counts, averages and durations, and shows the change the same way.
+func delta(current any, previous any) string {
+	change := deltaChange(current, previous)
+	if deltaNumber(previous) == 0 {
+		if deltaNumber(current) == 0 {
+			return change
+		}
+
+		return change + " (new)"
+	}
+
+	percent := (deltaNumber(current) - deltaNumber(previous)) / deltaNumber(previous) * 100
+	return fmt.Sprintf("%s (%+.0f%%)", change, percent)
+}
+
+func deltaChange(current any, previous any) string {
+	switch current := current.(type) {
+	case time.Duration:
+		change := current - previous.(time.Duration)
+		if change < 0 {
+			return "-" + humanDuration(-change)
+		}
+
+		return "+" + humanDuration(change)
+	case float64:
+		return fmt.Sprintf("%+.1f", current-previous.(float64))
+	default:
+		return fmt.Sprintf("%+d", current.(int)-previous.(int))
+	}
+}
+
+func deltaNumber(value any) float64 {
+	switch value := value.(type) {
+	case time.Duration:
+		return float64(value)
+	case float64:
+		return value
+	case int:
+		return float64(value)
+	default:
+		return 0
+	}
+}
+
+// deltaClass is "up" when current is more than previous. Pass them the other
+// way around for stats where less is better.
+func deltaClass(current any, previous any) string {
+	switch {
+	case deltaNumber(current) > deltaNumber(previous):
+		return "up"
+	case deltaNumber(current) < deltaNumber(previous):
+		return "down"
+	default:
+		return ""
+	}
+}
+
+// withQueryValue returns query as a query string with value added to key,
+// leaving every other parameter as it was.
+func withQueryValue(query url.Values, key string, value string) string {
+	q := maps.Clone(query)
+	if !slices.Contains(q[key], value) {
+		q[key] = append(slices.Clone(q[key]), value)
+	}
+
+	return "?" + q.Encode()
+}
+
+// withoutQueryValue returns query as a query string with value removed from
+// key, leaving every other parameter as it was.
+func withoutQueryValue(query url.Values, key string, value string) string {
+	q := maps.Clone(query)
+	q[key] = slices.DeleteFunc(slices.Clone(q[key]), func(v string) bool { return v == value })
+
+	return "?" + q.Encode()
+}
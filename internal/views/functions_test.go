@@ -1,6 +1,7 @@
 package views
 
 import (
+	"net/url"
 	"testing"
 	"time"
 
@@ -133,3 +134,64 @@ func TestHumanTimeDiff(t *testing.T) {
 		})
 	}
 }
+
+func TestHumanDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{d: 0, want: "0s"},
+		{d: 45*time.Second + 400*time.Millisecond, want: "45s"},
+		{d: 3*time.Minute + 5*time.Second, want: "3m 05s"},
+		{d: 62*time.Minute + 30*time.Second, want: "1h 02m"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.want, func(t *testing.T) {
+			assert.Equal(t, humanDuration(tc.d), tc.want)
+		})
+	}
+}
+
+func TestDelta(t *testing.T) {
+	tests := []struct {
+		name      string
+		current   any
+		previous  any
+		want      string
+		wantClass string
+	}{
+		{name: "Increase", current: 15, previous: 10, want: "+5 (+50%)", wantClass: "up"},
+		{name: "Decrease", current: 5, previous: 20, want: "-15 (-75%)", wantClass: "down"},
+		{name: "No change", current: 7, previous: 7, want: "+0 (+0%)", wantClass: ""},
+		{name: "Nothing to compare against", current: 3, previous: 0, want: "+3 (new)", wantClass: "up"},
+		{name: "Both zero", current: 0, previous: 0, want: "+0", wantClass: ""},
+		{name: "Average", current: 2.5, previous: 2.0, want: "+0.5 (+25%)", wantClass: "up"},
+		{name: "Average from zero", current: 1.5, previous: 0.0, want: "+1.5 (new)", wantClass: "up"},
+		{name: "Duration", current: 45 * time.Second, previous: 90 * time.Second, want: "-45s (-50%)", wantClass: "down"},
+		{name: "Unchanged duration", current: time.Duration(0), previous: time.Duration(0), want: "+0s", wantClass: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, delta(tc.current, tc.previous), tc.want)
+			assert.Equal(t, deltaClass(tc.current, tc.previous), tc.wantClass)
+		})
+	}
+}
+
+func TestQueryValues(t *testing.T) {
+	query := url.Values{"range": {"7d"}, "action": {"pageview"}}
+
+	t.Run("Adding a value", func(t *testing.T) {
+		assert.Equal(t, withQueryValue(query, "action", "signup"), "?action=pageview&action=signup&range=7d")
+		assert.Equal(t, withQueryValue(query, "action", "pageview"), "?action=pageview&range=7d")
+		assert.Equal(t, len(query["action"]), 1)
+	})
+
+	t.Run("Removing a value", func(t *testing.T) {
+		assert.Equal(t, withoutQueryValue(query, "action", "pageview"), "?range=7d")
+		assert.Equal(t, withoutQueryValue(query, "action", "signup"), "?action=pageview&range=7d")
+		assert.Equal(t, len(query["action"]), 1)
+	})
+}
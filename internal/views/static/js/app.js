@@ -1,6 +1,7 @@
 document.addEventListener("DOMContentLoaded", function () {
   setTimezoneFieldDefault();
   initializeCharts();
+  initializeRealtime();
 });
 
 document.addEventListener("click", function (e) {
@@ -27,6 +28,27 @@ document.addEventListener("click", function (e) {
   }
 });
 
+document.addEventListener("change", function (e) {
+  if (!e.target.hasAttribute("data-auto-submit")) return;
+
+  e.target.form.submit();
+});
+
+document.addEventListener("change", function (e) {
+  if (!e.target.hasAttribute("data-custom-range")) return;
+
+  const rangeEl = e.target.form.querySelector('[name="range"]');
+  if (rangeEl) rangeEl.value = "custom";
+});
+
+document.addEventListener("change", function (e) {
+  if (e.target.getAttribute("name") !== "range") return;
+
+  // A granularity that suited the old range may be too fine for the new one.
+  const granularityEl = e.target.form.querySelector('[name="granularity"]');
+  if (granularityEl) granularityEl.value = "";
+});
+
 function setTimezoneFieldDefault() {
   const timezoneEl = document.querySelector('[name="timezone"]');
   if (timezoneEl) {
@@ -58,19 +80,117 @@ function initializeCharts() {
       chartOptions.indexAxis = 'y'
     }
 
+    let labels = Object.keys(data);
+    const datasets = [
+      {
+        label: el.dataset.chartLabel,
+        data: Object.values(data),
+        ...datasetOptions[chartType],
+      },
+    ];
+
+    if (el.dataset.chartCompareData) {
+      const compareData = JSON.parse(el.dataset.chartCompareData);
+      let compareValues;
+
+      if (chartType === 'line') {
+        // Periods are the same length, so buckets line up by position.
+        compareValues = Object.values(compareData).slice(0, labels.length);
+      } else {
+        labels = [...new Set([...labels, ...Object.keys(compareData)])];
+        datasets[0].data = labels.map((label) => data[label] || 0);
+        compareValues = labels.map((label) => compareData[label] || 0);
+      }
+
+      datasets.push({ label: el.dataset.chartCompareLabel, data: compareValues, fill: false });
+      chartOptions.plugins.legend.display = true;
+      chartOptions.plugins.tooltip.callbacks = {
+        footer: (items) => formatDelta(datasets[0].data[items[0].dataIndex], compareValues[items[0].dataIndex]),
+      };
+    }
+
+    if (el.dataset.chartFilter) {
+      chartOptions.onClick = (_e, elements) => {
+        if (elements.length === 0) return;
+
+        addFilter(el.dataset.chartFilter, labels[elements[0].index]);
+      };
+      chartOptions.onHover = (e, elements) => {
+        e.native.target.style.cursor = elements.length > 0 ? "pointer" : "default";
+      };
+    }
+
     new Chart(el, {
       type: chartType,
       options: chartOptions,
-      data: {
-        labels: Object.keys(data),
-        datasets: [
-          {
-            label: el.dataset.chartLabel,
-            data: Object.values(data),
-            ...datasetOptions[chartType],
-          },
-        ],
-      },
+      data: { labels, datasets },
     });
   });
 }
+
+function initializeRealtime() {
+  const el = document.querySelector("[data-realtime-url]");
+  if (!el || !window.EventSource) return;
+
+  const statusEl = el.querySelector("[data-realtime-status]");
+  const source = new EventSource(el.dataset.realtimeUrl);
+  source.addEventListener("stats", (e) => {
+    const stats = JSON.parse(e.data);
+    statusEl.textContent = "Live";
+    el.querySelector("[data-realtime-visitors]").textContent = stats.visitors;
+    renderRealtimeCounts(el.querySelector("[data-realtime-actions]"), stats.actions);
+    renderRealtimeCounts(el.querySelector("[data-realtime-referrers]"), stats.referrers);
+  });
+
+  // EventSource reconnects on its own after errors.
+  source.addEventListener("error", () => {
+    statusEl.textContent = "Reconnecting";
+  });
+}
+
+function renderRealtimeCounts(tbody, counts) {
+  const rows = counts.map((c) => {
+    const row = document.createElement("tr");
+    [c.name, c.count].forEach((value) => {
+      const cell = document.createElement("td");
+      cell.textContent = value;
+      row.appendChild(cell);
+    });
+
+    return row;
+  });
+
+  if (rows.length === 0) {
+    const row = document.createElement("tr");
+    const cell = document.createElement("td");
+    cell.colSpan = 2;
+    cell.textContent = "Nothing yet.";
+    row.appendChild(cell);
+    rows.push(row);
+  }
+
+  tbody.replaceChildren(...rows);
+}
+
+function addFilter(dimension, value) {
+  const url = new URL(window.location.href);
+  const filter = `${dimension}:eq:${value}`;
+  if (!url.searchParams.getAll("filter").includes(filter)) {
+    url.searchParams.append("filter", filter);
+  }
+
+  window.location.assign(url);
+}
+
+function formatDelta(current, previous) {
+  if (current === undefined || previous === undefined) return "";
+
+  const change = current - previous;
+  const sign = change < 0 ? "" : "+";
+  if (previous === 0) {
+    return current === 0 ? "+0" : `${sign}${change} (new)`;
+  }
+
+  const percent = Math.round((change / previous) * 100);
+  return `${sign}${change} (${sign}${percent}%)`;
+}
@@ -3,7 +3,7 @@ function eh(action, data) {
 
   var projectKey = document.querySelector('meta[name="eh_project_key"]')?.getAttribute("content");
   projectKey ||= window.EH_PROJECT_KEY;
-  if (!projectKey) return;
+  if (!projectKey || ehOptedOut()) return;
 
   var baseURL = document.querySelector('meta[name="eh_url"]')?.getAttribute("content");
   baseURL ||= "https://eh.robyparr.com";
@@ -13,11 +13,58 @@ function eh(action, data) {
   xhr.setRequestHeader("Content-type", "application/json; charset=UTF-8");
   xhr.setRequestHeader("Authorization", "Bearer " + projectKey);
 
-  var referrer = document.referrer === '' ? undefined : new URL(document.referrer).host;
+  var referrer = document.referrer === '' ? undefined : document.referrer;
+  if (referrer && new URL(referrer).host === window.location.host) referrer = undefined;
   var jsonBody = JSON.stringify({
     action: action,
     count: data.count || 1,
-    referrer: referrer === window.location.host ? undefined : referrer
+    props: data.props,
+    url: window.location.href,
+    utm: ehCampaign(),
+    referrer: referrer
   });
   xhr.send(jsonBody);
 }
+
+// ehOptOut stops this browser sending events from the site, or starts it again
+// when optedOut is false. It's kept in the site's own storage, so unlike the
+// opt-out page it works in browsers that block third-party cookies.
+function ehOptOut(optedOut) {
+  try {
+    if (optedOut === false) {
+      window.localStorage.removeItem("eh_opt_out");
+    } else {
+      window.localStorage.setItem("eh_opt_out", "1");
+    }
+  } catch (e) {}
+}
+
+function ehOptedOut() {
+  try {
+    return window.localStorage.getItem("eh_opt_out") === "1";
+  } catch (e) {
+    return false;
+  }
+}
+
+// ehCampaign remembers the campaign parameters a visitor landed with for the
+// rest of their browser session, so later events like signups are attributed
+// to the campaign that brought them in.
+function ehCampaign() {
+  var keys = ["source", "medium", "campaign", "term", "content"];
+  var params = new URLSearchParams(window.location.search);
+  var utm = {};
+  keys.forEach(function(key) {
+    if (params.get("utm_" + key)) utm[key] = params.get("utm_" + key);
+  });
+
+  try {
+    if (Object.keys(utm).length > 0) {
+      window.sessionStorage.setItem("eh_utm", JSON.stringify(utm));
+    } else {
+      utm = JSON.parse(window.sessionStorage.getItem("eh_utm")) || {};
+    }
+  } catch (e) {}
+
+  return Object.keys(utm).length > 0 ? utm : undefined;
+}
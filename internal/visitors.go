@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// visitorSalt caches the salt for the current UTC day so ingestion doesn't
+// need to look it up for every event.
+type visitorSalt struct {
+	mu    sync.RWMutex
+	day   string
+	value string
+}
+
+// VisitorID identifies a visitor to a site without cookies or storing their
+// IP address. It's a hash of the day's salt, the site, the visitor's IP and
+// their user agent, so the same visitor gets a new ID every day and on every
+// site.
+func (app *App) VisitorID(siteID int64, ip string, userAgent string) (string, error) {
+	salt, err := app.currentVisitorSalt(time.Now())
+	if err != nil {
+		return "", fmt.Errorf("[VisitorID] %w", err)
+	}
+
+	h := sha256.New()
+	for _, part := range []string{salt, strconv.FormatInt(siteID, 10), ip, userAgent} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:16]), nil
+}
+
+// RotateVisitorSalt switches to the salt for the day of now and deletes every
+// earlier one.
+func (app *App) RotateVisitorSalt(now time.Time) (int64, error) {
+	_, err := app.currentVisitorSalt(now)
+	if err != nil {
+		return 0, fmt.Errorf("[RotateVisitorSalt] %w", err)
+	}
+
+	deleted, err := app.Repos.Salts.DeleteBefore(now)
+	if err != nil {
+		return 0, fmt.Errorf("[RotateVisitorSalt] %w", err)
+	}
+
+	return deleted, nil
+}
+
+// currentVisitorSalt returns the salt for the day of now, loading it if the
+// cached salt is from an earlier day.
+func (app *App) currentVisitorSalt(now time.Time) (string, error) {
+	day := now.UTC().Format(time.DateOnly)
+
+	app.visitorSalt.mu.RLock()
+	cachedDay, salt := app.visitorSalt.day, app.visitorSalt.value
+	app.visitorSalt.mu.RUnlock()
+
+	if cachedDay == day {
+		return salt, nil
+	}
+
+	salt, err := app.Repos.Salts.FindOrCreate(now)
+	if err != nil {
+		return "", err
+	}
+
+	app.visitorSalt.mu.Lock()
+	app.visitorSalt.day = day
+	app.visitorSalt.value = salt
+	app.visitorSalt.mu.Unlock()
+
+	return salt, nil
+}
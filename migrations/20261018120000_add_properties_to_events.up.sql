@@ -0,0 +1,3 @@
+ALTER TABLE events ADD COLUMN properties JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX idx_events_properties ON events USING GIN (properties);
@@ -0,0 +1,13 @@
+CREATE TABLE api_keys (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name VARCHAR(100) NOT NULL,
+  token VARCHAR NOT NULL,
+  token_prefix VARCHAR(16) NOT NULL,
+  last_used_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (NOW() AT TIME ZONE 'UTC'),
+  updated_at TIMESTAMP NOT NULL DEFAULT (NOW() AT TIME ZONE 'UTC')
+);
+
+CREATE INDEX idx_api_keys_user_id ON api_keys(user_id);
+CREATE UNIQUE INDEX idx_api_keys_token_unique ON api_keys(token);
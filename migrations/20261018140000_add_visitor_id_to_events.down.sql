@@ -0,0 +1,4 @@
+DROP TABLE visitor_salts;
+
+DROP INDEX idx_events_site_id_visitor_id;
+ALTER TABLE events DROP COLUMN visitor_id;
@@ -0,0 +1,9 @@
+ALTER TABLE events ADD COLUMN visitor_id VARCHAR(32);
+
+CREATE INDEX idx_events_site_id_visitor_id ON events(site_id, visitor_id);
+
+CREATE TABLE visitor_salts (
+  day DATE PRIMARY KEY,
+  salt VARCHAR NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT (NOW() AT TIME ZONE 'UTC')
+);
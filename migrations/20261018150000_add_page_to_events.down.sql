@@ -0,0 +1,2 @@
+ALTER TABLE events DROP COLUMN path;
+ALTER TABLE events DROP COLUMN hostname;
@@ -0,0 +1,2 @@
+ALTER TABLE events ADD COLUMN hostname TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN path TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+ALTER TABLE events DROP COLUMN utm_content;
+ALTER TABLE events DROP COLUMN utm_term;
+ALTER TABLE events DROP COLUMN utm_campaign;
+ALTER TABLE events DROP COLUMN utm_medium;
+ALTER TABLE events DROP COLUMN utm_source;
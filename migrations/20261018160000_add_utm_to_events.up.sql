@@ -0,0 +1,5 @@
+ALTER TABLE events ADD COLUMN utm_source TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN utm_medium TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN utm_campaign TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN utm_term TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN utm_content TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+ALTER TABLE events DROP COLUMN channel;
+ALTER TABLE events DROP COLUMN referrer_source;
+ALTER TABLE events DROP COLUMN referrer_url;
@@ -0,0 +1,5 @@
+ALTER TABLE events ADD COLUMN referrer_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN referrer_source TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN channel TEXT NOT NULL DEFAULT 'Direct';
+
+UPDATE events SET channel = 'Referral' WHERE referrer IS NOT NULL OR utm_source <> '';
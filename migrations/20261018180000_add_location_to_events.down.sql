@@ -0,0 +1,3 @@
+ALTER TABLE events DROP COLUMN city;
+ALTER TABLE events DROP COLUMN region;
+ALTER TABLE events DROP COLUMN country;
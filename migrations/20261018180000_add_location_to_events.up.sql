@@ -0,0 +1,3 @@
+ALTER TABLE events ADD COLUMN country TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN region TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN city TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,11 @@
+CREATE TABLE funnels (
+  id BIGSERIAL PRIMARY KEY,
+  site_id BIGINT NOT NULL REFERENCES sites(id) ON DELETE CASCADE,
+  name VARCHAR(100) NOT NULL,
+  window_seconds INTEGER NOT NULL,
+  steps JSONB NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT (NOW() AT TIME ZONE 'UTC'),
+  updated_at TIMESTAMP NOT NULL DEFAULT (NOW() AT TIME ZONE 'UTC')
+);
+
+CREATE INDEX idx_funnels_site_id ON funnels(site_id);
@@ -0,0 +1 @@
+ALTER TABLE sites ADD COLUMN allowed_hostnames TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1 @@
+ALTER TABLE sites DROP COLUMN excluded_ips;
@@ -0,0 +1 @@
+ALTER TABLE sites ADD COLUMN excluded_ips TEXT[] NOT NULL DEFAULT '{}';
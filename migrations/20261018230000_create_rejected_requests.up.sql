@@ -0,0 +1,7 @@
+CREATE TABLE rejected_requests (
+  site_id BIGINT NOT NULL REFERENCES sites(id) ON DELETE CASCADE,
+  day DATE NOT NULL,
+  reason VARCHAR(32) NOT NULL,
+  count BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (site_id, day, reason)
+);